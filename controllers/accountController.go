@@ -0,0 +1,192 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/gmkanat/Go-Shop/models"
+	"github.com/gmkanat/Go-Shop/utils"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ExportMe [...] Download every piece of personal data we hold as JSON
+func (uc *UserController) ExportMe(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+	uc.DB.First(&currentUser.Role, currentUser.RoleId)
+
+	export := models.UserExport{
+		ExportedAt: time.Now(),
+		Profile: models.UserExportProfile{
+			ID:              currentUser.ID,
+			Name:            currentUser.Name,
+			Email:           currentUser.Email,
+			Role:            currentUser.Role.Name,
+			EmailVerifiedAt: currentUser.EmailVerifiedAt,
+			TwoFactor:       currentUser.TOTPEnabledAt != nil,
+			CreatedAt:       currentUser.CreatedAt,
+			UpdatedAt:       currentUser.UpdatedAt,
+		},
+		Orders:             []models.UserExportOrder{},
+		Ratings:            []models.UserExportRating{},
+		Comments:           []models.UserExportComment{},
+		Items:              []models.UserExportItem{},
+		Sessions:           []models.SessionResponse{},
+		APIKeys:            []models.APIKeyResponse{},
+		SellerApplications: []models.SellerApplication{},
+		Addresses:          []models.Address{},
+	}
+
+	var orders []models.Order
+	uc.DB.Preload("Lines").Where("user_id = ?", currentUser.ID).Order("id").Find(&orders)
+	for _, order := range orders {
+		exportOrder := models.UserExportOrder{
+			ID: order.ID, Status: order.Status, Comment: order.Comment, ShippingCost: order.ShippingCost,
+			Total: order.Total, ShipTo: order.ShippingAddress, CreatedAt: order.CreatedAt, Lines: []models.UserExportOrderLine{},
+		}
+		for _, line := range order.Lines {
+			exportOrder.Lines = append(exportOrder.Lines, models.UserExportOrderLine{
+				ItemID: line.ItemID, ItemName: line.ItemName, UnitPrice: line.UnitPrice, Quantity: line.Quantity,
+			})
+		}
+		export.Orders = append(export.Orders, exportOrder)
+	}
+	uc.DB.Table("item_ratings").
+		Select("item_ratings.id, item_ratings.item_id, items.name as item_name, item_ratings.rating").
+		Joins("LEFT JOIN items ON item_ratings.item_id = items.id").
+		Where("item_ratings.user_id = ?", currentUser.ID).
+		Order("item_ratings.id").Scan(&export.Ratings)
+	uc.DB.Table("item_comments").
+		Select("item_comments.id, item_comments.item_id, items.name as item_name, item_comments.comment").
+		Joins("LEFT JOIN items ON item_comments.item_id = items.id").
+		Where("item_comments.user_id = ?", currentUser.ID).
+		Order("item_comments.id").Scan(&export.Comments)
+	uc.DB.Table("items").Select("id, name, price").
+		Where("seller_id = ?", currentUser.ID).Order("id").Scan(&export.Items)
+
+	var sessions []models.Session
+	uc.DB.Where("user_id = ?", currentUser.ID).Order("created_at").Find(&sessions)
+	for _, session := range sessions {
+		export.Sessions = append(export.Sessions, models.SessionResponse{
+			ID:         session.ID,
+			IPAddress:  session.IPAddress,
+			UserAgent:  session.UserAgent,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+		})
+	}
+
+	var keys []models.APIKey
+	uc.DB.Where("user_id = ?", currentUser.ID).Order("id").Find(&keys)
+	for _, key := range keys {
+		export.APIKeys = append(export.APIKeys, newAPIKeyResponse(key))
+	}
+
+	uc.DB.Where("user_id = ?", currentUser.ID).Order("id").Find(&export.SellerApplications)
+	uc.DB.Where("user_id = ?", currentUser.ID).Order("id").Find(&export.Addresses)
+
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="go-shop-export-%d.json"`, currentUser.ID))
+	ctx.JSON(http.StatusOK, export)
+}
+
+// DeleteMe [...] Anonymize the current account
+//
+// Orders, ratings and comments keep pointing at the user row so sellers'
+// records stay intact; only the personal data on it is wiped.
+func (uc *UserController) DeleteMe(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	var payload *models.DeleteAccountInput
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+	if err := utils.VerifyPassword(currentUser.Password, payload.Password); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "Password is incorrect"})
+		return
+	}
+
+	var openOrders int64
+	uc.DB.Model(&models.Order{}).
+		Where("(orders.user_id = ? OR EXISTS (SELECT 1 FROM order_lines WHERE order_lines.order_id = orders.id AND order_lines.seller_id = ?)) AND orders.status NOT IN ?",
+			currentUser.ID, currentUser.ID, models.OrderClosedStatuses).
+		Count(&openOrders)
+	if openOrders > 0 {
+		ctx.JSON(http.StatusConflict, gin.H{
+			"status": "fail", "message": "You have open orders, wait until they are completed or cancel them first",
+		})
+		return
+	}
+
+	var listings int64
+	uc.DB.Model(&models.Item{}).Where("seller_id = ?", currentUser.ID).Count(&listings)
+	if listings > 0 {
+		ctx.JSON(http.StatusConflict, gin.H{
+			"status": "fail", "message": "You have active listings, delete them first",
+		})
+		return
+	}
+
+	password, err := utils.GenerateOpaqueToken(32)
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	err = uc.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(&currentUser).Updates(map[string]interface{}{
+			"name":              "Deleted user",
+			"email":             fmt.Sprintf("deleted-%d@deleted.invalid", currentUser.ID),
+			"password":          hashedPassword,
+			"email_verified_at": nil,
+			"totp_secret":       "",
+			"totp_enabled_at":   nil,
+			"anonymized_at":     now,
+			"updated_at":        now,
+		}).Error; err != nil {
+			return err
+		}
+
+		// Any failure rolls the anonymization back, so the account is never
+		// reported deleted with credentials still active.
+		if err := revokeAllUserTokens(tx, currentUser.ID); err != nil {
+			return err
+		}
+		if err := tx.Model(&models.APIKey{}).Where("user_id = ? AND revoked_at IS NULL", currentUser.ID).
+			Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Session{}).Where("user_id = ?", currentUser.ID).
+			Updates(map[string]interface{}{"ip_address": "", "user_agent": ""}).Error; err != nil {
+			return err
+		}
+		for _, owned := range []interface{}{
+			&models.RecoveryCode{}, &models.PasswordResetToken{}, &models.EmailChangeRequest{}, &models.Address{},
+		} {
+			if err := tx.Where("user_id = ?", currentUser.ID).Delete(owned).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("seller_id = ?", currentUser.ID).Delete(&models.ShippingMethod{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Order{}).Where("user_id = ?", currentUser.ID).Updates(map[string]interface{}{
+			"shipping_recipient_name": "", "shipping_line1": "", "shipping_line2": "", "shipping_city": "",
+			"shipping_region": "", "shipping_postal_code": "", "shipping_country": "", "shipping_phone": "",
+		}).Error
+	})
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": "Something bad happened"})
+		return
+	}
+
+	clearAuthCookies(ctx)
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "message": "Your account has been deleted"})
+}
@@ -0,0 +1,192 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/gmkanat/Go-Shop/models"
+	"github.com/gmkanat/Go-Shop/utils"
+	"gorm.io/gorm"
+	"net/http"
+	"strings"
+)
+
+var (
+	errNoShippingAddress = errors.New("add a shipping address or pass address_id")
+	errUnknownAddress    = errors.New("address not found")
+)
+
+// ListAddresses [...] Address book of the current user, default first
+func (uc *UserController) ListAddresses(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	addresses := []models.Address{}
+	uc.DB.Where("user_id = ?", currentUser.ID).Order("is_default DESC, id").Find(&addresses)
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "addresses": addresses})
+}
+
+// CreateAddress [...] Add an address; the first one becomes the default
+func (uc *UserController) CreateAddress(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	var payload *models.AddressInput
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+	address := models.Address{UserID: currentUser.ID}
+	if err := applyAddressInput(&address, payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+
+	err := uc.DB.Transaction(func(tx *gorm.DB) error {
+		var existing int64
+		tx.Model(&models.Address{}).Where("user_id = ?", currentUser.ID).Count(&existing)
+		address.IsDefault = payload.IsDefault || existing == 0
+		if err := tx.Create(&address).Error; err != nil {
+			return err
+		}
+		if address.IsDefault {
+			return setDefaultAddress(tx, address)
+		}
+		return nil
+	})
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusCreated, gin.H{"status": "success", "address": address})
+}
+
+// UpdateAddress [...] Replace an address of the current user
+func (uc *UserController) UpdateAddress(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	var payload *models.AddressInput
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+	var address models.Address
+	uc.DB.Where("id = ? AND user_id = ?", ctx.Param("id"), currentUser.ID).First(&address)
+	if address.ID == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": errUnknownAddress.Error()})
+		return
+	}
+	if err := applyAddressInput(&address, payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+
+	err := uc.DB.Transaction(func(tx *gorm.DB) error {
+		// Unsetting the flag isn't supported: pick another default instead.
+		address.IsDefault = address.IsDefault || payload.IsDefault
+		if err := tx.Save(&address).Error; err != nil {
+			return err
+		}
+		if address.IsDefault {
+			return setDefaultAddress(tx, address)
+		}
+		return nil
+	})
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "address": address})
+}
+
+// SetDefaultAddress [...] Make an address the default one
+func (uc *UserController) SetDefaultAddress(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	var address models.Address
+	uc.DB.Where("id = ? AND user_id = ?", ctx.Param("id"), currentUser.ID).First(&address)
+	if address.ID == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": errUnknownAddress.Error()})
+		return
+	}
+	if err := uc.DB.Transaction(func(tx *gorm.DB) error { return setDefaultAddress(tx, address) }); err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	address.IsDefault = true
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "address": address})
+}
+
+// DeleteAddress [...] Remove an address; orders keep their own copy
+//
+// Deleting the default address promotes the most recently added one left.
+func (uc *UserController) DeleteAddress(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	var address models.Address
+	uc.DB.Where("id = ? AND user_id = ?", ctx.Param("id"), currentUser.ID).First(&address)
+	if address.ID == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": errUnknownAddress.Error()})
+		return
+	}
+	err := uc.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&address).Error; err != nil {
+			return err
+		}
+		if !address.IsDefault {
+			return nil
+		}
+		var next models.Address
+		tx.Where("user_id = ?", currentUser.ID).Order("id DESC").First(&next)
+		if next.ID == 0 {
+			return nil
+		}
+		return setDefaultAddress(tx, next)
+	})
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+func applyAddressInput(address *models.Address, payload *models.AddressInput) error {
+	country, postalCode, err := utils.NormalizeAddress(payload.Country, payload.PostalCode)
+	if err != nil {
+		return err
+	}
+	address.Label = strings.TrimSpace(payload.Label)
+	address.RecipientName = strings.TrimSpace(payload.RecipientName)
+	address.Line1 = strings.TrimSpace(payload.Line1)
+	address.Line2 = strings.TrimSpace(payload.Line2)
+	address.City = strings.TrimSpace(payload.City)
+	address.Region = strings.TrimSpace(payload.Region)
+	address.PostalCode = postalCode
+	address.Country = country
+	address.Phone = strings.TrimSpace(payload.Phone)
+	return nil
+}
+
+// setDefaultAddress makes address the user's only default address.
+func setDefaultAddress(tx *gorm.DB, address models.Address) error {
+	if err := tx.Model(&models.Address{}).Where("user_id = ? AND id <> ?", address.UserID, address.ID).
+		Update("is_default", false).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.Address{}).Where("id = ?", address.ID).Update("is_default", true).Error
+}
+
+// shippingAddress returns the snapshot of the address an order should ship
+// to: addressID if given, otherwise the user's default address.
+func shippingAddress(tx *gorm.DB, userID uint, addressID uint) (models.OrderAddress, error) {
+	var address models.Address
+	if addressID != 0 {
+		tx.Where("id = ? AND user_id = ?", addressID, userID).First(&address)
+		if address.ID == 0 {
+			return models.OrderAddress{}, errUnknownAddress
+		}
+	} else {
+		tx.Where("user_id = ? AND is_default", userID).First(&address)
+		if address.ID == 0 {
+			return models.OrderAddress{}, errNoShippingAddress
+		}
+	}
+	return address.Snapshot(), nil
+}
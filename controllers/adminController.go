@@ -0,0 +1,272 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gmkanat/Go-Shop/models"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errUnknownRole         = errors.New("unknown role")
+	errUnknownPermission   = errors.New("unknown permission")
+	errApplicationReviewed = errors.New("application has already been reviewed")
+)
+
+type AdminController struct {
+	DB *gorm.DB
+}
+
+func NewAdminController(DB *gorm.DB) AdminController {
+	return AdminController{DB}
+}
+
+// ListPermissions [...] List all permissions
+func (ac *AdminController) ListPermissions(ctx *gin.Context) {
+	var permissions []models.Permission
+	ac.DB.Order("name").Find(&permissions)
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "permissions": permissions})
+}
+
+// ListRoles [...] List all roles with their permissions
+func (ac *AdminController) ListRoles(ctx *gin.Context) {
+	var roles []models.UserRole
+	ac.DB.Preload("Permissions").Order("id").Find(&roles)
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "roles": roles})
+}
+
+// CreateRole [...] Create a role such as "moderator"
+func (ac *AdminController) CreateRole(ctx *gin.Context) {
+	var payload *models.RoleInput
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+
+	permissions, err := findPermissions(ac.DB, payload.Permissions)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+	if err := canGrantPermissions(ctx.MustGet("currentUser").(models.User), permissions); err != nil {
+		ctx.JSON(http.StatusForbidden, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+
+	role := models.UserRole{Name: strings.ToLower(strings.TrimSpace(payload.Name)), Permissions: permissions}
+	if result := ac.DB.Create(&role); result.Error != nil {
+		ctx.JSON(http.StatusConflict, gin.H{"status": "fail", "message": "role already exists"})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"status": "success", "role": role})
+}
+
+// SetRolePermissions [...] Replace the permissions of a role
+func (ac *AdminController) SetRolePermissions(ctx *gin.Context) {
+	var payload *models.RolePermissionsInput
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+
+	var role models.UserRole
+	ac.DB.First(&role, ctx.Param("id"))
+	if role.ID == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "role not found"})
+		return
+	}
+	if role.Name == models.RoleAdmin {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "the admin role always has every permission"})
+		return
+	}
+
+	permissions, err := findPermissions(ac.DB, payload.Permissions)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+	if err := canGrantPermissions(ctx.MustGet("currentUser").(models.User), permissions); err != nil {
+		ctx.JSON(http.StatusForbidden, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+
+	if err := ac.DB.Model(&role).Association("Permissions").Replace(permissions); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+	role.Permissions = permissions
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "role": role})
+}
+
+// canGrantPermissions keeps non-admins from handing out permissions they do
+// not hold themselves, otherwise roles:manage would be enough to escalate to
+// full admin rights.
+func canGrantPermissions(user models.User, permissions []models.Permission) error {
+	if user.Role.Name == models.RoleAdmin {
+		return nil
+	}
+	for _, permission := range permissions {
+		if !user.Role.HasPermission(permission.Name) {
+			return fmt.Errorf("you cannot grant a permission you do not hold: %s", permission.Name)
+		}
+	}
+	return nil
+}
+
+func findPermissions(tx *gorm.DB, names []string) ([]models.Permission, error) {
+	permissions := []models.Permission{}
+	if len(names) == 0 {
+		return permissions, nil
+	}
+	tx.Where("name IN ?", names).Find(&permissions)
+	if len(permissions) != len(names) {
+		return nil, errUnknownPermission
+	}
+	return permissions, nil
+}
+
+// ListSellerApplications [...] List seller applications, pending by default
+func (ac *AdminController) ListSellerApplications(ctx *gin.Context) {
+	status := ctx.DefaultQuery("status", models.SellerApplicationPending)
+
+	var applications []models.SellerApplicationResponse
+	ac.DB.Table("seller_applications").
+		Select("seller_applications.id, seller_applications.user_id, users.name as user_name, users.email as user_email, "+
+			"seller_applications.message, seller_applications.status, seller_applications.review_note, "+
+			"seller_applications.reviewed_at, seller_applications.created_at").
+		Joins("INNER JOIN users ON seller_applications.user_id = users.id").
+		Where("seller_applications.status = ?", status).
+		Order("seller_applications.id").
+		Scan(&applications)
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "applications": applications})
+}
+
+// ApproveSellerApplication [...] Approve an application and grant the seller role
+func (ac *AdminController) ApproveSellerApplication(ctx *gin.Context) {
+	ac.reviewSellerApplication(ctx, models.SellerApplicationApproved)
+}
+
+// RejectSellerApplication [...] Reject an application
+func (ac *AdminController) RejectSellerApplication(ctx *gin.Context) {
+	ac.reviewSellerApplication(ctx, models.SellerApplicationRejected)
+}
+
+func (ac *AdminController) reviewSellerApplication(ctx *gin.Context, status string) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	var payload models.SellerApplicationReview
+	_ = ctx.ShouldBindJSON(&payload)
+
+	var application models.SellerApplication
+	var failStatus int
+	var failMessage string
+	err := ac.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&application, ctx.Param("id"))
+		if result.Error != nil {
+			failStatus, failMessage = http.StatusNotFound, "application not found"
+			return result.Error
+		}
+		if application.Status != models.SellerApplicationPending {
+			failStatus, failMessage = http.StatusConflict, "application has already been reviewed"
+			return errApplicationReviewed
+		}
+
+		now := time.Now()
+		application.Status = status
+		application.ReviewNote = payload.Note
+		application.ReviewedByID = &currentUser.ID
+		application.ReviewedAt = &now
+		if err := tx.Save(&application).Error; err != nil {
+			return err
+		}
+
+		if status == models.SellerApplicationApproved {
+			return setUserRole(tx, application.UserID, models.RoleSeller)
+		}
+		return nil
+	})
+	if failStatus != 0 {
+		ctx.JSON(failStatus, gin.H{"status": "fail", "message": failMessage})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "application": application})
+}
+
+// GrantRole [...] Assign a role to a user
+func (ac *AdminController) GrantRole(ctx *gin.Context) {
+	var payload *models.RoleChange
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+	ac.changeUserRole(ctx, payload.Role)
+}
+
+// RevokeRole [...] Put a user back to the default buyer role
+func (ac *AdminController) RevokeRole(ctx *gin.Context) {
+	ac.changeUserRole(ctx, models.RoleBuyer)
+}
+
+func (ac *AdminController) changeUserRole(ctx *gin.Context, role string) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	var user models.User
+	ac.DB.Preload("Role.Permissions").First(&user, ctx.Param("id"))
+	if user.ID == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "user not found"})
+		return
+	}
+	if user.ID == currentUser.ID {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "you cannot change your own role"})
+		return
+	}
+	// roles:manage alone must not be enough to create or demote an admin.
+	if (role == models.RoleAdmin || user.Role.Name == models.RoleAdmin) && currentUser.Role.Name != models.RoleAdmin {
+		ctx.JSON(http.StatusForbidden, gin.H{"status": "fail", "message": "only admins can grant or revoke the admin role"})
+		return
+	}
+	// Nor may it hand out, or take away, permissions the caller doesn't hold.
+	var target models.UserRole
+	if err := ac.DB.Preload("Permissions").First(&target, "name = ?", role).Error; err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": errUnknownRole.Error()})
+		return
+	}
+	if err := canGrantPermissions(currentUser, target.Permissions); err != nil {
+		ctx.JSON(http.StatusForbidden, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+	if err := canGrantPermissions(currentUser, user.Role.Permissions); err != nil {
+		ctx.JSON(http.StatusForbidden, gin.H{"status": "fail", "message": "you cannot change the role of a user with permissions you do not hold"})
+		return
+	}
+
+	if err := setUserRole(ac.DB, user.ID, role); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "user_id": user.ID, "role": role})
+}
+
+func setUserRole(tx *gorm.DB, userID uint, roleName string) error {
+	var role models.UserRole
+	if err := tx.First(&role, "name = ?", roleName).Error; err != nil {
+		return errUnknownRole
+	}
+	return tx.Model(&models.User{}).Where("id = ?", userID).
+		Updates(map[string]interface{}{"role_id": role.ID, "updated_at": time.Now()}).Error
+}
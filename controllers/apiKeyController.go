@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"github.com/gmkanat/Go-Shop/models"
+	"github.com/gmkanat/Go-Shop/utils"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListAPIKeys [...] List API keys of the current user
+func (uc *UserController) ListAPIKeys(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	var keys []models.APIKey
+	uc.DB.Where("user_id = ?", currentUser.ID).Order("id DESC").Find(&keys)
+
+	keysResponse := make([]models.APIKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		keysResponse = append(keysResponse, newAPIKeyResponse(key))
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "api_keys": keysResponse})
+}
+
+// CreateAPIKey [...] Create an API key; the secret is only returned once
+func (uc *UserController) CreateAPIKey(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	var payload *models.APIKeyInput
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+
+	for _, scope := range payload.Scopes {
+		if !isAPIKeyScope(scope) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "unknown scope " + scope})
+			return
+		}
+	}
+
+	secret, err := utils.GenerateOpaqueToken(24)
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	rawKey := "gs_" + secret
+
+	key := models.APIKey{
+		UserID:    currentUser.ID,
+		Name:      payload.Name,
+		Prefix:    rawKey[:11],
+		KeyHash:   utils.HashToken(rawKey),
+		Scopes:    strings.Join(payload.Scopes, " "),
+		CreatedAt: time.Now(),
+	}
+	if result := uc.DB.Create(&key); result.Error != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": result.Error.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"status": "success", "api_key": newAPIKeyResponse(key), "key": rawKey})
+}
+
+// RevokeAPIKey [...] Revoke an API key of the current user
+func (uc *UserController) RevokeAPIKey(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	var key models.APIKey
+	uc.DB.Where("user_id = ?", currentUser.ID).First(&key, ctx.Param("id"))
+	if key.ID == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "API key not found"})
+		return
+	}
+
+	if key.RevokedAt == nil {
+		now := time.Now()
+		if result := uc.DB.Model(&key).Update("revoked_at", now); result.Error != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": result.Error.Error()})
+			return
+		}
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "api_key": newAPIKeyResponse(key)})
+}
+
+func newAPIKeyResponse(key models.APIKey) models.APIKeyResponse {
+	return models.APIKeyResponse{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     strings.Fields(key.Scopes),
+		LastUsedAt: key.LastUsedAt,
+		RevokedAt:  key.RevokedAt,
+		CreatedAt:  key.CreatedAt,
+	}
+}
+
+func isAPIKeyScope(scope string) bool {
+	for _, known := range models.APIKeyScopes {
+		if scope == known {
+			return true
+		}
+	}
+	return false
+}
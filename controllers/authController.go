@@ -1,23 +1,33 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
 	"github.com/gmkanat/Go-Shop/initializers"
+	"github.com/gmkanat/Go-Shop/mailer"
 	"github.com/gmkanat/Go-Shop/models"
+	"github.com/gmkanat/Go-Shop/ratelimit"
 	"github.com/gmkanat/Go-Shop/utils"
+	"log"
+	"math"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type AuthController struct {
-	DB *gorm.DB
+	DB             *gorm.DB
+	Mailer         mailer.Mailer
+	RateLimitStore ratelimit.Store
 }
 
-func NewAuthController(DB *gorm.DB) AuthController {
-	return AuthController{DB}
+func NewAuthController(DB *gorm.DB, mailer mailer.Mailer, rateLimitStore ratelimit.Store) AuthController {
+	return AuthController{DB, mailer, rateLimitStore}
 }
 
 // SignUpUser [...] SignUp User
@@ -46,6 +56,15 @@ func (ac *AuthController) SignUpUser(ctx *gin.Context) {
 		return
 	}
 
+	// Every account starts as a buyer; other roles are granted by an admin.
+	var buyerRole models.UserRole
+	if ac.DB.First(&buyerRole, "name = ?", models.RoleBuyer).Error != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{
+			"status": "error", "message": "Default role is not configured, run the migrations",
+		})
+		return
+	}
+
 	now := time.Now()
 	newUser := models.User{
 		Name:      payload.Name,
@@ -53,7 +72,7 @@ func (ac *AuthController) SignUpUser(ctx *gin.Context) {
 		Password:  hashedPassword,
 		CreatedAt: now,
 		UpdatedAt: now,
-		RoleId:    payload.RoleId,
+		RoleId:    buyerRole.ID,
 	}
 
 	result := ac.DB.Create(&newUser)
@@ -66,7 +85,77 @@ func (ac *AuthController) SignUpUser(ctx *gin.Context) {
 	}
 
 	ac.DB.Save(newUser)
-	ctx.JSON(http.StatusCreated, gin.H{"status": "success"})
+
+	config, _ := initializers.LoadConfig(".")
+	if err := ac.sendVerificationEmail(newUser, &config); err != nil {
+		log.Println("could not send verification email:", err)
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{
+		"status": "success", "message": "We sent a verification link to " + newUser.Email,
+	})
+}
+
+// VerifyEmail [...] Confirm the email address from a verification link
+func (ac *AuthController) VerifyEmail(ctx *gin.Context) {
+	claims, err := utils.ValidatePurposeToken(ctx.Query("token"), verifyEmailPurpose, initializers.TokenKeys)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"status": "fail", "message": "Invalid or expired verification link",
+		})
+		return
+	}
+
+	var user models.User
+	if ac.DB.First(&user, "id = ?", fmt.Sprint(claims.Subject)).Error != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"status": "fail", "message": "Invalid or expired verification link",
+		})
+		return
+	}
+
+	if user.EmailVerifiedAt == nil {
+		now := time.Now()
+		if err := ac.DB.Model(&user).Update("email_verified_at", now).Error; err != nil {
+			ctx.JSON(http.StatusBadGateway, gin.H{
+				"status": "error", "message": "Something bad happened",
+			})
+			return
+		}
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "message": "Email verified"})
+}
+
+// ResendVerification [...] Send a new verification link
+func (ac *AuthController) ResendVerification(ctx *gin.Context) {
+	var payload *models.ResendVerificationInput
+
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"status": "fail", "message": err.Error(),
+		})
+		return
+	}
+
+	// Always answer the same way so the endpoint can't be used to probe
+	// which addresses are registered.
+	var user models.User
+	result := ac.DB.First(&user, "email = ?", strings.ToLower(payload.Email))
+	if result.Error == nil && user.EmailVerifiedAt == nil {
+		// Send in the background so the response time doesn't give away
+		// that the address is registered either.
+		config, _ := initializers.LoadConfig(".")
+		go func() {
+			if err := ac.sendVerificationEmail(user, &config); err != nil {
+				log.Println("could not send verification email:", err)
+			}
+		}()
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"status": "success", "message": "If the account exists and is unverified, a new link has been sent",
+	})
 }
 
 // SignInUser [...] SignIn User
@@ -80,9 +169,17 @@ func (ac *AuthController) SignInUser(ctx *gin.Context) {
 		return
 	}
 
+	config, _ := initializers.LoadConfig(".")
+	email := strings.ToLower(payload.Email)
+
+	if ac.loginLockedOut(ctx, &config, email) {
+		return
+	}
+
 	var user models.User
-	result := ac.DB.First(&user, "email = ?", strings.ToLower(payload.Email))
+	result := ac.DB.First(&user, "email = ?", email)
 	if result.Error != nil {
+		ac.recordLoginFailure(ctx, &config)
 		ctx.JSON(http.StatusBadRequest, gin.H{
 			"status": "fail", "message": "Invalid email or Password",
 		})
@@ -90,32 +187,445 @@ func (ac *AuthController) SignInUser(ctx *gin.Context) {
 	}
 
 	if err := utils.VerifyPassword(user.Password, payload.Password); err != nil {
+		ac.recordLoginFailure(ctx, &config)
 		ctx.JSON(http.StatusBadRequest, gin.H{
 			"status": "fail", "message": "Invalid email or Password",
 		})
 		return
 	}
 
+	if user.TOTPEnabledAt != nil {
+		challenge, _, err := utils.GeneratePurposeToken(
+			config.TwoFactorChallengeExpiresIn, user.ID, twoFactorChallengePurpose, initializers.TokenKeys,
+		)
+		if err != nil {
+			ctx.JSON(http.StatusBadGateway, gin.H{
+				"status": "error", "message": err.Error(),
+			})
+			return
+		}
+
+		ctx.JSON(http.StatusAccepted, gin.H{
+			"status": "success", "two_factor_required": true, "challenge_token": challenge,
+		})
+		return
+	}
+
+	ac.recordLoginSuccess(&config, email)
+	ac.startSession(ctx, user, &config)
+}
+
+// startSession records a new login session for user and responds with its
+// access and refresh tokens.
+func (ac *AuthController) startSession(ctx *gin.Context, user models.User, config *initializers.Config) {
+	familyID, err := utils.GenerateOpaqueToken(16)
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{
+			"status": "error", "message": err.Error(),
+		})
+		return
+	}
+
+	var token, refreshToken string
+	err = ac.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		session := models.Session{
+			ID:         familyID,
+			UserID:     user.ID,
+			IPAddress:  ctx.ClientIP(),
+			UserAgent:  ctx.Request.UserAgent(),
+			CreatedAt:  now,
+			LastSeenAt: now,
+			ExpiresAt:  now.Add(config.RefreshTokenExpiresIn),
+		}
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+
+		var err error
+		token, refreshToken, err = ac.issueTokens(tx, user, familyID, config)
+		return err
+	})
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"status": "fail", "message": err.Error(),
+		})
+		return
+	}
+
+	setAuthCookies(ctx, token, refreshToken, config)
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"status": "success", "token": token, "refresh_token": refreshToken, "session_id": familyID,
+	})
+}
+
+// RefreshAccessToken [...] Rotate refresh token and issue a new access token
+func (ac *AuthController) RefreshAccessToken(ctx *gin.Context) {
+	var payload models.RefreshInput
+	_ = ctx.ShouldBindJSON(&payload)
+
+	presented := payload.RefreshToken
+	if presented == "" {
+		presented, _ = ctx.Cookie("refresh_token")
+	}
+	if presented == "" {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"status": "fail", "message": "Refresh token is missing",
+		})
+		return
+	}
+
 	config, _ := initializers.LoadConfig(".")
 
-	token, err := utils.GenerateToken(
-		config.AccessTokenExpiresIn, user.ID, config.TokenSecret,
-	)
+	var token, refreshToken string
+	err := ac.DB.Transaction(func(tx *gorm.DB) error {
+		var stored models.RefreshToken
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&stored, "token_hash = ?", utils.HashToken(presented))
+		if result.Error != nil {
+			return errInvalidRefreshToken
+		}
+
+		if stored.UsedAt != nil || stored.RevokedAt != nil {
+			return errRefreshTokenReused
+		}
+		if time.Now().After(stored.ExpiresAt) {
+			return errInvalidRefreshToken
+		}
+
+		now := time.Now()
+		if err := tx.Model(&stored).Update("used_at", now).Error; err != nil {
+			return err
+		}
+
+		var user models.User
+		if err := tx.First(&user, stored.UserID).Error; err != nil {
+			return errInvalidRefreshToken
+		}
+
+		var err error
+		token, refreshToken, err = ac.issueTokens(tx, user, stored.FamilyID, &config)
+		return err
+	})
+
+	if errors.Is(err, errRefreshTokenReused) {
+		// A rotated token was presented again: assume it was stolen and
+		// kill every token issued from the same login.
+		revokeRefreshFamilyByToken(ac.DB, presented)
+	}
+	if err != nil {
+		clearAuthCookies(ctx)
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"status": "fail", "message": err.Error(),
+		})
+		return
+	}
+
+	setAuthCookies(ctx, token, refreshToken, &config)
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "token": token, "refresh_token": refreshToken})
+}
+
+func (ac *AuthController) LogoutUser(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+	claims := ctx.MustGet("currentToken").(*utils.TokenClaims)
+
+	revokeAccessToken(ac.DB, currentUser.ID, claims.ID, claims.ExpiresAt)
+	if familyID := sessionIDForToken(ac.DB, claims); familyID != "" {
+		revokeRefreshFamily(ac.DB, familyID)
+	}
+	if refreshToken, err := ctx.Cookie("refresh_token"); err == nil {
+		revokeRefreshFamilyByToken(ac.DB, refreshToken)
+	}
+	clearAuthCookies(ctx)
+	ctx.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+// LogoutAllSessions [...] Revoke every token issued to the current user
+func (ac *AuthController) LogoutAllSessions(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+	claims := ctx.MustGet("currentToken").(*utils.TokenClaims)
+
+	revokeAllUserTokens(ac.DB, currentUser.ID)
+	revokeAccessToken(ac.DB, currentUser.ID, claims.ID, claims.ExpiresAt)
+	clearAuthCookies(ctx)
+	ctx.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+// ForgotPassword [...] Email a password reset link
+func (ac *AuthController) ForgotPassword(ctx *gin.Context) {
+	var payload *models.ForgotPasswordInput
+
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"status": "fail", "message": err.Error(),
+		})
+		return
+	}
+
+	var user models.User
+	result := ac.DB.First(&user, "email = ?", strings.ToLower(payload.Email))
+	if result.Error == nil {
+		// Creating the token and sending the mail happen in the background
+		// so known and unknown addresses take the same time to answer.
+		config, _ := initializers.LoadConfig(".")
+		go func() {
+			if err := ac.sendPasswordResetEmail(user, &config); err != nil {
+				log.Println("could not send password reset email:", err)
+			}
+		}()
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"status": "success", "message": "If the account exists, a password reset link has been sent",
+	})
+}
+
+// ResetPassword [...] Set a new password using a reset token
+func (ac *AuthController) ResetPassword(ctx *gin.Context) {
+	var payload *models.ResetPasswordInput
+
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"status": "fail", "message": err.Error(),
+		})
+		return
+	}
+
+	if payload.Password != payload.PasswordConfirm {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"status": "fail", "message": "Passwords do not match",
+		})
+		return
+	}
+
+	hashedPassword, err := utils.HashPassword(payload.Password)
 	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{
+			"status": "error", "message": err.Error(),
+		})
+		return
+	}
+
+	err = ac.DB.Transaction(func(tx *gorm.DB) error {
+		var resetToken models.PasswordResetToken
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&resetToken, "token_hash = ?", utils.HashToken(payload.Token))
+		if result.Error != nil || resetToken.UsedAt != nil || time.Now().After(resetToken.ExpiresAt) {
+			return errInvalidResetToken
+		}
+
+		now := time.Now()
+		if err := tx.Model(&resetToken).Update("used_at", now).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.User{}).Where("id = ?", resetToken.UserID).
+			Updates(map[string]interface{}{"password": hashedPassword, "updated_at": now}).Error; err != nil {
+			return err
+		}
+
+		return revokeAllUserTokens(tx, resetToken.UserID)
+	})
+	if errors.Is(err, errInvalidResetToken) {
 		ctx.JSON(http.StatusBadRequest, gin.H{
 			"status": "fail", "message": err.Error(),
 		})
 		return
 	}
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{
+			"status": "error", "message": "Something bad happened",
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "message": "Password has been reset, please log in"})
+}
 
+// JWKS [...] Public keys other services use to verify our tokens
+func (ac *AuthController) JWKS(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(http.StatusOK, gin.H{"keys": initializers.TokenKeys.JWKS()})
+}
+
+// loginLockouts returns the per-account and per-IP failed login trackers.
+func (ac *AuthController) loginLockouts(config *initializers.Config) (*ratelimit.Lockout, *ratelimit.Lockout) {
+	account := &ratelimit.Lockout{
+		Store:     ac.RateLimitStore,
+		Threshold: config.LoginMaxAttempts,
+		Window:    config.LoginAttemptWindow,
+		BaseDelay: config.LoginLockoutBase,
+		MaxDelay:  config.LoginLockoutMax,
+	}
+	ip := *account
+	ip.Threshold = config.LoginIPMaxAttempts
+	return account, &ip
+}
+
+// loginLockedOut counts a login attempt against the account before the
+// credentials are checked and responds 429 when either the account or the
+// client IP is locked out. The IP only counts failures (see
+// recordLoginFailure), so users sharing an address aren't locked out by each
+// other's successful logins.
+func (ac *AuthController) loginLockedOut(ctx *gin.Context, config *initializers.Config, email string) bool {
+	account, ip := ac.loginLockouts(config)
+	accountWait, err := account.Attempt("login:account:" + email)
+	if err != nil {
+		log.Println("could not record login attempt:", err)
+	}
+	ipWait, err := ip.RetryAfter("login:ip:" + ctx.ClientIP())
+	if err != nil {
+		log.Println("could not check failed logins:", err)
+	}
+	if ipWait > accountWait {
+		accountWait = ipWait
+	}
+	if accountWait <= 0 {
+		return false
+	}
+
+	seconds := int(math.Ceil(accountWait.Seconds()))
+	ctx.Header("Retry-After", fmt.Sprint(seconds))
+	ctx.JSON(http.StatusTooManyRequests, gin.H{
+		"status": "fail", "message": "Too many failed login attempts, please try again later", "retry_after": seconds,
+	})
+	return true
+}
+
+// recordLoginFailure counts a failed login against the client IP; the
+// account attempt was already counted by loginLockedOut.
+func (ac *AuthController) recordLoginFailure(ctx *gin.Context, config *initializers.Config) {
+	_, ip := ac.loginLockouts(config)
+	if err := ip.Fail("login:ip:" + ctx.ClientIP()); err != nil {
+		log.Println("could not record failed login:", err)
+	}
+}
+
+// recordLoginSuccess clears the account counter only, so one valid account
+// can't be used to reset the IP counter while spraying other accounts.
+func (ac *AuthController) recordLoginSuccess(config *initializers.Config, email string) {
+	account, _ := ac.loginLockouts(config)
+	if err := account.Succeed("login:account:" + email); err != nil {
+		log.Println("could not reset failed logins:", err)
+	}
+}
+
+const verifyEmailPurpose = "verify_email"
+
+func (ac *AuthController) sendVerificationEmail(user models.User, config *initializers.Config) error {
+	token, _, err := utils.GeneratePurposeToken(
+		config.EmailVerifyExpiresIn, user.ID, verifyEmailPurpose, initializers.TokenKeys,
+	)
+	if err != nil {
+		return err
+	}
+
+	link := config.AppURL + "/api/auth/verify?token=" + url.QueryEscape(token)
+	return ac.Mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your Go-Shop account",
+		Body: "Hi " + user.Name + ",\n\n" +
+			"Please confirm your email address by opening the link below:\n\n" +
+			link + "\n\n" +
+			"The link expires in " + config.EmailVerifyExpiresIn.String() + ".\n",
+	})
+}
+
+// sendPasswordResetEmail mails a link to the PASSWORD_RESET_URL page, a
+// frontend route that lets the user pick a new password and submits it with
+// the token to POST /api/auth/reset-password.
+func (ac *AuthController) sendPasswordResetEmail(user models.User, config *initializers.Config) error {
+	if config.PasswordResetURL == "" {
+		return errors.New("PASSWORD_RESET_URL is not configured")
+	}
+	token, err := utils.GenerateOpaqueToken(32)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	err = ac.DB.Transaction(func(tx *gorm.DB) error {
+		// Only the most recent link stays usable.
+		if err := tx.Model(&models.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", user.ID).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.PasswordResetToken{
+			UserID:    user.ID,
+			TokenHash: utils.HashToken(token),
+			ExpiresAt: now.Add(config.PasswordResetExpiresIn),
+			CreatedAt: now,
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	link := config.PasswordResetURL + "?token=" + url.QueryEscape(token)
+	return ac.Mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your Go-Shop password",
+		Body: "Hi " + user.Name + ",\n\n" +
+			"Someone asked to reset the password of your account. If it was you, open the link below:\n\n" +
+			link + "\n\n" +
+			"The link expires in " + config.PasswordResetExpiresIn.String() + " and can be used once. " +
+			"If you did not ask for this, you can ignore this email.\n",
+	})
+}
+
+var (
+	errInvalidResetToken   = errors.New("invalid or expired reset token")
+	errInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	errRefreshTokenReused  = errors.New("refresh token reuse detected, please log in again")
+)
+
+// issueTokens signs an access token for user and persists a new refresh
+// token belonging to familyID.
+func (ac *AuthController) issueTokens(tx *gorm.DB, user models.User, familyID string, config *initializers.Config) (string, string, error) {
+	token, claims, err := utils.GenerateSessionToken(
+		config.AccessTokenExpiresIn, user.ID, familyID, initializers.TokenKeys,
+	)
+	if err != nil {
+		return "", "", err
+	}
+
+	refreshToken, err := utils.GenerateOpaqueToken(32)
+	if err != nil {
+		return "", "", err
+	}
+
+	now := time.Now()
+	stored := models.RefreshToken{
+		UserID:               user.ID,
+		FamilyID:             familyID,
+		TokenHash:            utils.HashToken(refreshToken),
+		ExpiresAt:            now.Add(config.RefreshTokenExpiresIn),
+		CreatedAt:            now,
+		AccessTokenID:        claims.ID,
+		AccessTokenExpiresAt: claims.ExpiresAt,
+	}
+	if err := tx.Create(&stored).Error; err != nil {
+		return "", "", err
+	}
+
+	tx.Model(&models.Session{}).Where("id = ?", familyID).
+		Updates(map[string]interface{}{"last_seen_at": now, "expires_at": stored.ExpiresAt})
+
+	return token, refreshToken, nil
+}
+
+func setAuthCookies(ctx *gin.Context, token string, refreshToken string, config *initializers.Config) {
 	ctx.SetCookie("token", token, config.AccessTokenMaxAge*60,
 		"/", "localhost", false, true)
-
-	ctx.JSON(http.StatusOK, gin.H{"status": "success", "token": token})
+	ctx.SetCookie("refresh_token", refreshToken, config.RefreshTokenMaxAge*60,
+		"/api/auth", "localhost", false, true)
 }
 
-func (ac *AuthController) LogoutUser(ctx *gin.Context) {
+func clearAuthCookies(ctx *gin.Context) {
 	ctx.SetCookie("token", "", -1, "/",
 		"localhost", false, true)
-	ctx.JSON(http.StatusOK, gin.H{"status": "success"})
+	ctx.SetCookie("refresh_token", "", -1, "/api/auth",
+		"localhost", false, true)
 }
@@ -0,0 +1,280 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/gmkanat/Go-Shop/initializers"
+	"github.com/gmkanat/Go-Shop/models"
+	"github.com/gmkanat/Go-Shop/shipping"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"net/http"
+	"strconv"
+)
+
+var errEmptyCart = errors.New("cart is empty")
+
+type CartController struct {
+	DB *gorm.DB
+}
+
+func NewCartController(DB *gorm.DB) CartController {
+	return CartController{DB}
+}
+
+// GetCart [...] Cart of the current user with computed subtotals
+func (cc *CartController) GetCart(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	cart, err := cc.userCart(cc.DB, currentUser.ID)
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "cart": cc.cartResponse(cart)})
+}
+
+// AddToCart [...] Add an item to the cart, or add to its quantity if it's already there
+func (cc *CartController) AddToCart(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	var payload *models.CartItemInput
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+	var item models.Item
+	cc.DB.First(&item, payload.ItemID)
+	if item.ID == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "item not found"})
+		return
+	}
+
+	cart, err := cc.userCart(cc.DB, currentUser.ID)
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	err = cc.DB.Transaction(func(tx *gorm.DB) error {
+		var line models.CartItem
+		tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("cart_id = ? AND item_id = ?", cart.ID, item.ID).First(&line)
+		if line.ID != 0 {
+			return tx.Model(&line).Update("quantity", line.Quantity+payload.Quantity).Error
+		}
+		return tx.Create(&models.CartItem{CartID: cart.ID, ItemID: item.ID, Quantity: payload.Quantity}).Error
+	})
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+	cc.respondCart(ctx, cart.UserID)
+}
+
+// UpdateCartItem [...] Set the quantity of an item in the cart
+func (cc *CartController) UpdateCartItem(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	var payload *models.CartQuantityInput
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+	cart, err := cc.userCart(cc.DB, currentUser.ID)
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	result := cc.DB.Model(&models.CartItem{}).
+		Where("cart_id = ? AND item_id = ?", cart.ID, ctx.Param("item_id")).
+		Update("quantity", payload.Quantity)
+	if result.Error != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "item is not in the cart"})
+		return
+	}
+	cc.respondCart(ctx, cart.UserID)
+}
+
+// RemoveCartItem [...] Remove an item from the cart
+func (cc *CartController) RemoveCartItem(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	cart, err := cc.userCart(cc.DB, currentUser.ID)
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	result := cc.DB.Where("cart_id = ? AND item_id = ?", cart.ID, ctx.Param("item_id")).Delete(&models.CartItem{})
+	if result.Error != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "item is not in the cart"})
+		return
+	}
+	cc.respondCart(ctx, cart.UserID)
+}
+
+// ClearCart [...] Remove everything from the cart
+func (cc *CartController) ClearCart(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	cart, err := cc.userCart(cc.DB, currentUser.ID)
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	if err := cc.DB.Where("cart_id = ?", cart.ID).Delete(&models.CartItem{}).Error; err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+	cc.respondCart(ctx, cart.UserID)
+}
+
+// Checkout [...] Turn the whole cart into a single pending order
+//
+// The cart row is locked for the duration so two concurrent checkouts can't
+// both order the same lines.
+func (cc *CartController) Checkout(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	var payload models.CheckoutInput
+	if ctx.Request.ContentLength > 0 {
+		if err := ctx.ShouldBindJSON(&payload); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+			return
+		}
+	}
+
+	config, _ := initializers.LoadConfig(".")
+	shipTo, err := shippingAddress(cc.DB, currentUser.ID, payload.AddressID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+
+	newOrder := models.Order{UserID: currentUser.ID, Comment: payload.Comment, ShippingAddress: shipTo}
+	err = cc.DB.Transaction(func(tx *gorm.DB) error {
+		cart, err := cc.userCart(tx.Clauses(clause.Locking{Strength: "UPDATE"}), currentUser.ID)
+		if err != nil {
+			return err
+		}
+		if len(cart.Items) == 0 {
+			return errEmptyCart
+		}
+		var lines []shippingLine
+		for _, line := range cart.Items {
+			newOrder.Lines = append(newOrder.Lines, newOrderLine(line.Item, line.Quantity))
+			lines = append(lines, shippingLine{line.Item, line.Quantity})
+		}
+		newOrder.Shipments, err = planShipments(tx, lines, shipTo, payload.ShippingMethodIDs)
+		if err != nil {
+			return err
+		}
+		if err := createOrder(tx, &newOrder, models.OrderActorBuyer, &currentUser.ID, config.ReservationExpiresIn); err != nil {
+			return err
+		}
+		return tx.Where("cart_id = ?", cart.ID).Delete(&models.CartItem{}).Error
+	})
+	if errors.Is(err, errEmptyCart) || errors.Is(err, shipping.ErrNotDeliverable) || errors.Is(err, errShippingMethodUnavailable) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+	if err != nil {
+		respondOrderError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, gin.H{"status": "success", "order": models.OrderResponse{
+		ID:           newOrder.ID,
+		Status:       newOrder.Status,
+		Subtotal:     newOrder.Subtotal,
+		ShippingCost: newOrder.ShippingCost,
+		Total:        newOrder.Total,
+		Lines:        newOrder.Lines,
+		Shipments:    newOrder.Shipments,
+	}})
+}
+
+// ShippingQuotes [...] Shipping options for the cart, per seller
+//
+// Quotes are for the address_id query parameter, or the default address.
+func (cc *CartController) ShippingQuotes(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	addressID, _ := strconv.Atoi(ctx.Query("address_id"))
+	shipTo, err := shippingAddress(cc.DB, currentUser.ID, uint(addressID))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+	cart, err := cc.userCart(cc.DB, currentUser.ID)
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	var lines []shippingLine
+	for _, line := range cart.Items {
+		lines = append(lines, shippingLine{line.Item, line.Quantity})
+	}
+	options, err := shippingOptions(cc.DB, lines, shipTo)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "shipping": options})
+}
+
+// userCart returns the user's cart with its items, creating an empty cart
+// on first use. The insert skips conflicts on carts.user_id, so when two
+// first requests race both read back the same cart.
+func (cc *CartController) userCart(tx *gorm.DB, userID uint) (models.Cart, error) {
+	var cart models.Cart
+	find := func() error {
+		return tx.Where("user_id = ?", userID).
+			Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("cart_items.id") }).
+			Preload("Items.Item").
+			First(&cart).Error
+	}
+
+	err := find()
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return cart, err
+	}
+	if err := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "user_id"}}, DoNothing: true}).
+		Create(&models.Cart{UserID: userID}).Error; err != nil {
+		return cart, err
+	}
+	return cart, find()
+}
+
+func (cc *CartController) respondCart(ctx *gin.Context, userID uint) {
+	cart, err := cc.userCart(cc.DB, userID)
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "cart": cc.cartResponse(cart)})
+}
+
+func (cc *CartController) cartResponse(cart models.Cart) models.CartResponse {
+	response := models.CartResponse{Items: []models.CartLineResponse{}}
+	for _, line := range cart.Items {
+		subtotal := roundMoney(line.Item.Price * float64(line.Quantity))
+		response.Items = append(response.Items, models.CartLineResponse{
+			ItemID:    line.ItemID,
+			Name:      line.Item.Name,
+			UnitPrice: line.Item.Price,
+			Quantity:  line.Quantity,
+			Subtotal:  subtotal,
+		})
+		response.ItemCount += line.Quantity
+		response.Subtotal += subtotal
+	}
+	response.Subtotal = roundMoney(response.Subtotal)
+	return response
+}
@@ -2,7 +2,9 @@ package controllers
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/gmkanat/Go-Shop/initializers"
 	"github.com/gmkanat/Go-Shop/models"
+	"github.com/gmkanat/Go-Shop/payments"
 	"gorm.io/gorm"
 	"net/http"
 	"strconv"
@@ -10,18 +12,19 @@ import (
 )
 
 type ItemController struct {
-	DB *gorm.DB
+	DB       *gorm.DB
+	Provider payments.Provider
 }
 
-func NewItemController(DB *gorm.DB) ItemController {
-	return ItemController{DB}
+func NewItemController(DB *gorm.DB, provider payments.Provider) ItemController {
+	return ItemController{DB, provider}
 }
 
 // GetItems [...] Get all items
 func (ic *ItemController) GetItems(ctx *gin.Context) {
 	var items []models.ItemList
 	query := ic.DB.Table("items").
-		Select("items.id, items.name, items.price, AVG(item_ratings.rating) as avg_rating, users.name as seller_name").
+		Select("items.id, items.name, items.price, items.stock - items.reserved as stock, AVG(item_ratings.rating) as avg_rating, users.name as seller_name").
 		Joins("LEFT JOIN item_ratings ON items.id = item_ratings.item_id").
 		Joins("INNER JOIN users ON items.seller_id = users.id").
 		Group("items.id, users.name").
@@ -61,6 +64,7 @@ func (ic *ItemController) GetItem(ctx *gin.Context) {
 			items.id, 
 			items.name, 
 			items.price, 
+			items.stock - items.reserved as stock,
 			AVG(item_ratings.rating) as avg_rating, 
 			users.name as seller_name,
 			json_agg(item_comments.comment) as comments
@@ -87,10 +91,31 @@ func (ic *ItemController) CreateItem(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
 		return
 	}
+	// ItemChange is shared with updates, where stock is optional.
+	if payload.Stock == nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "stock is required"})
+		return
+	}
 	newItem := models.Item{
 		Name:     payload.Name,
 		Price:    payload.Price,
 		SellerID: ctx.MustGet("currentUser").(models.User).ID,
+		Stock:    *payload.Stock,
+	}
+	if payload.LowStockThreshold != nil {
+		newItem.LowStockThreshold = *payload.LowStockThreshold
+	}
+	if payload.WeightGrams != nil {
+		newItem.WeightGrams = *payload.WeightGrams
+	}
+	if payload.LengthCm != nil {
+		newItem.LengthCm = *payload.LengthCm
+	}
+	if payload.WidthCm != nil {
+		newItem.WidthCm = *payload.WidthCm
+	}
+	if payload.HeightCm != nil {
+		newItem.HeightCm = *payload.HeightCm
 	}
 
 	result := ic.DB.Create(&newItem)
@@ -99,8 +124,14 @@ func (ic *ItemController) CreateItem(ctx *gin.Context) {
 		return
 	}
 	newItemResponse := models.ItemChange{
-		Name:  payload.Name,
-		Price: payload.Price,
+		Name:              newItem.Name,
+		Price:             newItem.Price,
+		Stock:             &newItem.Stock,
+		LowStockThreshold: &newItem.LowStockThreshold,
+		WeightGrams:       &newItem.WeightGrams,
+		LengthCm:          &newItem.LengthCm,
+		WidthCm:           &newItem.WidthCm,
+		HeightCm:          &newItem.HeightCm,
 	}
 	ctx.JSON(http.StatusOK, gin.H{"status": "success", "item": newItemResponse})
 }
@@ -112,39 +143,76 @@ func (ic *ItemController) UpdateItem(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
 		return
 	}
-	itemID := ctx.Param("id")
-	var item models.Item
-	ic.DB.First(&item, itemID)
+	item := ctx.MustGet("currentItem").(models.Item)
+	// Only touch the columns that were sent: a full Save would overwrite stock
+	// sold since the item was loaded.
+	changes := map[string]interface{}{}
 	if payload.Price != 0 {
-		item.Price = payload.Price
+		changes["price"] = payload.Price
 	}
 	if payload.Name != "" {
-		item.Name = payload.Name
-	}
-	result := ic.DB.Save(&item)
-	if result.Error != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": result.Error.Error()})
-		return
-	}
+		changes["name"] = payload.Name
+	}
+	if payload.Stock != nil {
+		changes["stock"] = *payload.Stock
+	}
+	if payload.LowStockThreshold != nil {
+		changes["low_stock_threshold"] = *payload.LowStockThreshold
+	}
+	if payload.WeightGrams != nil {
+		changes["weight_grams"] = *payload.WeightGrams
+	}
+	if payload.LengthCm != nil {
+		changes["length_cm"] = *payload.LengthCm
+	}
+	if payload.WidthCm != nil {
+		changes["width_cm"] = *payload.WidthCm
+	}
+	if payload.HeightCm != nil {
+		changes["height_cm"] = *payload.HeightCm
+	}
+	if len(changes) > 0 {
+		query := ic.DB.Model(&item)
+		if payload.Stock != nil {
+			// Units held by pending orders can't be taken off the shelf.
+			query = query.Where("reserved <= ?", *payload.Stock)
+		}
+		result := query.Updates(changes)
+		if result.Error != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": result.Error.Error()})
+			return
+		}
+		if result.RowsAffected == 0 {
+			ctx.JSON(http.StatusConflict, gin.H{"status": "fail", "message": "stock can't be lower than the units reserved by pending orders"})
+			return
+		}
+	}
+	ic.DB.First(&item, item.ID)
 	newItemResponse := models.ItemChange{
-		Name:  item.Name,
-		Price: item.Price,
+		Name:              item.Name,
+		Price:             item.Price,
+		Stock:             &item.Stock,
+		LowStockThreshold: &item.LowStockThreshold,
+		WeightGrams:       &item.WeightGrams,
+		LengthCm:          &item.LengthCm,
+		WidthCm:           &item.WidthCm,
+		HeightCm:          &item.HeightCm,
 	}
 	ctx.JSON(http.StatusOK, gin.H{"status": "success", "item": newItemResponse})
 }
 
 // DeleteItem [...] Delete item
 func (ic *ItemController) DeleteItem(ctx *gin.Context) {
-	itemID := ctx.Param("id")
-	var item models.Item
-	ic.DB.First(&item, itemID)
-	if item.ID == 0 {
-		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "item not found"})
-		return
-	}
-	result := ic.DB.Delete(&item)
-	if result.Error != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": result.Error.Error()})
+	item := ctx.MustGet("currentItem").(models.Item)
+	err := ic.DB.Transaction(func(tx *gorm.DB) error {
+		// Drop the item from carts; orders keep their own snapshot of it.
+		if err := tx.Where("item_id = ?", item.ID).Delete(&models.CartItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&item).Error
+	})
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
 		return
 	}
 	ctx.JSON(http.StatusOK, gin.H{"status": "success"})
@@ -220,6 +288,31 @@ func (ic *ItemController) CommentItem(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, gin.H{"status": "success", "item_comment": NewItemCommentResponse})
 }
 
+// DeleteComment [...] Delete own comment, or any comment with comments:moderate
+func (ic *ItemController) DeleteComment(ctx *gin.Context) {
+	commentID := ctx.Param("id")
+	var comment models.ItemComment
+	ic.DB.First(&comment, commentID)
+	if comment.ID == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "comment not found"})
+		return
+	}
+	currentUser := ctx.MustGet("currentUser").(models.User)
+	if comment.UserID != currentUser.ID {
+		ic.DB.Preload("Permissions").First(&currentUser.Role, currentUser.RoleId)
+		if !currentUser.Role.HasPermission(models.PermCommentsModerate) {
+			ctx.JSON(http.StatusForbidden, gin.H{"status": "fail", "message": "You have not access"})
+			return
+		}
+	}
+	result := ic.DB.Delete(&comment)
+	if result.Error != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": result.Error.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
 func (ic *ItemController) PurchaseItem(ctx *gin.Context) {
 	itemID := ctx.Param("id")
 	var item models.Item
@@ -230,41 +323,82 @@ func (ic *ItemController) PurchaseItem(ctx *gin.Context) {
 	}
 	currentUser := ctx.MustGet("currentUser").(models.User)
 
-	newOrder := models.Order{
-		ItemID: item.ID,
-		UserID: currentUser.ID,
+	// The body is optional: an empty request buys a single unit.
+	var payload models.PurchaseInput
+	if ctx.Request.ContentLength > 0 {
+		if err := ctx.ShouldBindJSON(&payload); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+			return
+		}
 	}
-	result := ic.DB.Create(&newOrder)
-	if result.Error != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": result.Error.Error()})
+	if payload.Quantity == 0 {
+		payload.Quantity = 1
+	}
+
+	shipTo, err := shippingAddress(ic.DB, currentUser.ID, payload.AddressID)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+
+	var chosen []uint
+	if payload.ShippingMethodID != 0 {
+		chosen = append(chosen, payload.ShippingMethodID)
+	}
+	shipments, err := planShipments(ic.DB, []shippingLine{{item, payload.Quantity}}, shipTo, chosen)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+
+	newOrder := models.Order{
+		UserID:          currentUser.ID,
+		Comment:         payload.Comment,
+		Lines:           []models.OrderLine{newOrderLine(item, payload.Quantity)},
+		Shipments:       shipments,
+		ShippingAddress: shipTo,
+	}
+	config, _ := initializers.LoadConfig(".")
+	err = ic.DB.Transaction(func(tx *gorm.DB) error {
+		return createOrder(tx, &newOrder, models.OrderActorBuyer, &currentUser.ID, config.ReservationExpiresIn)
+	})
+	if err != nil {
+		respondOrderError(ctx, err)
 		return
 	}
 	NewOrderResponce := models.OrderResponse{
-		ID:     newOrder.ID,
-		Status: newOrder.Status,
+		ID:           newOrder.ID,
+		Status:       newOrder.Status,
+		Subtotal:     newOrder.Subtotal,
+		ShippingCost: newOrder.ShippingCost,
+		Total:        newOrder.Total,
+		Lines:        newOrder.Lines,
+		Shipments:    newOrder.Shipments,
 	}
 	ctx.JSON(http.StatusOK, gin.H{"status": "success", "order": NewOrderResponce})
 }
 
 func (ic *ItemController) OrderStatus(ctx *gin.Context) {
-	orderID := ctx.Param("id")
-	var order models.Order
-	ic.DB.First(&order, orderID)
-	if order.ID == 0 {
-		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "order not found"})
-		return
-	}
+	order := ctx.MustGet("currentOrder").(models.Order)
+	currentUser := ctx.MustGet("currentUser").(models.User)
 
 	var payload *models.OrderChange
 	if err := ctx.ShouldBindJSON(&payload); err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
 		return
 	}
-	order.Status = payload.Status
+	// A status applies to the whole order, so one seller can't move other
+	// sellers' lines along with their own (or refund what they were paid).
+	if !currentUser.Role.HasPermission(models.PermOrdersManageAny) && sellsOtherLines(ic.DB, order.ID, currentUser.ID) {
+		ctx.JSON(http.StatusForbidden, gin.H{"status": "fail", "message": "order has items from other sellers"})
+		return
+	}
 
-	result := ic.DB.Save(order)
-	if result.Error != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": result.Error.Error()})
+	err := ic.DB.Transaction(func(tx *gorm.DB) error {
+		return transitionOrder(tx, ic.Provider, &order, payload.Status, models.OrderActorSeller, &currentUser.ID, payload.Note)
+	})
+	if err != nil {
+		respondOrderError(ctx, err)
 		return
 	}
 	NewOrderResponce := models.OrderResponse{
@@ -0,0 +1,187 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/gmkanat/Go-Shop/models"
+	"gorm.io/gorm"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPerPage = 20
+	maxPerPage     = 100
+)
+
+// ListMyOrders [...] Orders of the current user, newest first
+//
+// Filters: status (comma separated), from and to (YYYY-MM-DD or RFC 3339,
+// on the creation time; a bare "to" date includes that whole day).
+// Pagination: page and per_page.
+func (uc *UserController) ListMyOrders(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	query := uc.DB.Model(&models.Order{}).Where("orders.user_id = ?", currentUser.ID)
+	query, err := filterOrders(ctx, query)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+	// Share the filters between the count and the page query.
+	query = query.Session(&gorm.Session{})
+	var total int64
+	query.Count(&total)
+
+	pagination := paginate(ctx, total)
+	orders := []models.OrderSummary{}
+	query.Select("orders.id, orders.status, orders.total, orders.created_at, orders.updated_at, " +
+		"(SELECT COALESCE(SUM(quantity), 0) FROM order_lines WHERE order_lines.order_id = orders.id) as item_count").
+		Order("orders.created_at DESC, orders.id DESC").
+		Offset((pagination.Page - 1) * pagination.PerPage).Limit(pagination.PerPage).
+		Scan(&orders)
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "orders": orders, "pagination": pagination})
+}
+
+// GetOrder [...] Order with its lines, sellers, status timeline, payments, returns and refunds
+func (uc *UserController) GetOrder(ctx *gin.Context) {
+	order := ctx.MustGet("currentOrder").(models.Order)
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "order": orderDetail(uc.DB, order)})
+}
+
+func orderDetail(DB *gorm.DB, order models.Order) models.OrderDetail {
+	var lines []models.OrderLine
+	DB.Where("order_id = ?", order.ID).Order("id").Find(&lines)
+
+	detail := models.OrderDetail{
+		ID:           order.ID,
+		Status:       order.Status,
+		Comment:      order.Comment,
+		Subtotal:     order.Subtotal,
+		ShippingCost: order.ShippingCost,
+		Total:        order.Total,
+		ShipTo:       order.ShippingAddress,
+		Shipments:    []models.OrderShipment{},
+		Lines:        []models.OrderDetailLine{},
+		Timeline:     orderTimeline(DB, order.ID),
+		Payments:     []models.PaymentResponse{},
+		Returns:      []models.ReturnRequest{},
+		Refunds:      []models.Refund{},
+		CreatedAt:    order.CreatedAt,
+		UpdatedAt:    order.UpdatedAt,
+	}
+	DB.Where("order_id = ?", order.ID).Order("id").Find(&detail.Shipments)
+
+	// Load every seller and still listed item of the order in one query each.
+	var sellerIDs, itemIDs []uint
+	for _, line := range lines {
+		sellerIDs = append(sellerIDs, line.SellerID)
+		itemIDs = append(itemIDs, line.ItemID)
+	}
+	sellers := map[uint]models.OrderSeller{}
+	items := map[uint]float64{}
+	if len(lines) > 0 {
+		var sellerRows []models.User
+		DB.Select("id, name").Where("id IN ?", sellerIDs).Find(&sellerRows)
+		for _, seller := range sellerRows {
+			sellers[seller.ID] = models.OrderSeller{ID: seller.ID, Name: seller.Name}
+		}
+		var itemRows []models.Item
+		DB.Select("id, price").Where("id IN ?", itemIDs).Find(&itemRows)
+		for _, item := range itemRows {
+			items[item.ID] = item.Price
+		}
+	}
+	for _, line := range lines {
+		detailLine := models.OrderDetailLine{OrderLine: line, Seller: sellers[line.SellerID]}
+		if price, ok := items[line.ItemID]; ok {
+			detailLine.StillListed = true
+			detailLine.CurrentPrice = &price
+		}
+		detail.Lines = append(detail.Lines, detailLine)
+	}
+
+	var payments []models.Payment
+	DB.Where("order_id = ?", order.ID).Order("id").Find(&payments)
+	for _, payment := range payments {
+		detail.Payments = append(detail.Payments, newPaymentResponse(payment))
+	}
+	DB.Preload("Lines").Preload("Photos").Where("order_id = ?", order.ID).Order("id").Find(&detail.Returns)
+	DB.Joins("JOIN payments ON payments.id = refunds.payment_id").
+		Where("payments.order_id = ?", order.ID).Order("refunds.id").Find(&detail.Refunds)
+	return detail
+}
+
+// filterOrders applies the status, from and to query parameters.
+func filterOrders(ctx *gin.Context, query *gorm.DB) (*gorm.DB, error) {
+	if status := ctx.Query("status"); status != "" {
+		statuses := strings.Split(status, ",")
+		for _, s := range statuses {
+			if !isOrderStatus(s) {
+				return nil, fmt.Errorf("unknown order status %q", s)
+			}
+		}
+		query = query.Where("orders.status IN ?", statuses)
+	}
+	if from := ctx.Query("from"); from != "" {
+		at, _, err := parseDateParam(from)
+		if err != nil {
+			return nil, fmt.Errorf("invalid from date: %w", err)
+		}
+		query = query.Where("orders.created_at >= ?", at)
+	}
+	if to := ctx.Query("to"); to != "" {
+		at, dateOnly, err := parseDateParam(to)
+		if err != nil {
+			return nil, fmt.Errorf("invalid to date: %w", err)
+		}
+		if dateOnly {
+			query = query.Where("orders.created_at < ?", at.AddDate(0, 0, 1))
+		} else {
+			query = query.Where("orders.created_at <= ?", at)
+		}
+	}
+	return query, nil
+}
+
+func isOrderStatus(status string) bool {
+	for _, s := range models.OrderStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+func parseDateParam(value string) (time.Time, bool, error) {
+	if at, err := time.Parse("2006-01-02", value); err == nil {
+		return at, true, nil
+	}
+	at, err := time.Parse(time.RFC3339, value)
+	return at, false, err
+}
+
+// paginate reads page and per_page, clamping them to sane values.
+func paginate(ctx *gin.Context, total int64) models.Pagination {
+	page, err := strconv.Atoi(ctx.Query("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	perPage, err := strconv.Atoi(ctx.Query("per_page"))
+	if err != nil || perPage < 1 {
+		perPage = defaultPerPage
+	}
+	if perPage > maxPerPage {
+		perPage = maxPerPage
+	}
+	return models.Pagination{
+		Page:       page,
+		PerPage:    perPage,
+		Total:      total,
+		TotalPages: int((total + int64(perPage) - 1) / int64(perPage)),
+	}
+}
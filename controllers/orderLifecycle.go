@@ -0,0 +1,327 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gmkanat/Go-Shop/models"
+	"github.com/gmkanat/Go-Shop/payments"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errNoPaymentProvider = errors.New("no payment provider is configured to refund with")
+
+// recordOrderEvent adds a note to the order's timeline without changing its
+// status, for things like returns that happen to an order in place.
+func recordOrderEvent(tx *gorm.DB, order models.Order, actor string, changedByID *uint, note string) error {
+	return tx.Create(&models.OrderStatusHistory{
+		OrderID:     order.ID,
+		FromStatus:  order.Status,
+		ToStatus:    order.Status,
+		Actor:       actor,
+		ChangedByID: changedByID,
+		Note:        note,
+		CreatedAt:   time.Now(),
+	}).Error
+}
+
+// newOrderLine snapshots the item's name, seller and current price.
+func newOrderLine(item models.Item, quantity int) models.OrderLine {
+	return models.OrderLine{
+		ItemID:    item.ID,
+		SellerID:  item.SellerID,
+		ItemName:  item.Name,
+		UnitPrice: item.Price,
+		Quantity:  quantity,
+		Subtotal:  roundMoney(item.Price * float64(quantity)),
+	}
+}
+
+// roundMoney rounds an amount to whole cents.
+func roundMoney(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// createOrder inserts a pending order with its lines and shipments together
+// with the first entry of its timeline, and reserves stock for it for
+// reserveFor.
+func createOrder(tx *gorm.DB, order *models.Order, actor string, changedByID *uint, reserveFor time.Duration) error {
+	if len(order.Lines) == 0 {
+		return errors.New("order has no items")
+	}
+	order.Status = models.OrderPending
+	order.Subtotal = 0
+	for _, line := range order.Lines {
+		order.Subtotal += line.Subtotal
+	}
+	order.ShippingCost = 0
+	for _, shipment := range order.Shipments {
+		order.ShippingCost += shipment.Cost
+	}
+	order.Subtotal = roundMoney(order.Subtotal)
+	order.ShippingCost = roundMoney(order.ShippingCost)
+	order.Total = roundMoney(order.Subtotal + order.ShippingCost)
+	if err := tx.Create(order).Error; err != nil {
+		return err
+	}
+	if err := reserveStock(tx, order, time.Now().Add(reserveFor)); err != nil {
+		return err
+	}
+	return tx.Create(&models.OrderStatusHistory{
+		OrderID:     order.ID,
+		ToStatus:    models.OrderPending,
+		Actor:       actor,
+		ChangedByID: changedByID,
+		CreatedAt:   time.Now(),
+	}).Error
+}
+
+// transitionOrder moves the order to status on behalf of actor, rejecting
+// changes the lifecycle does not allow, and records the change in the
+// order's timeline. The order row is locked so concurrent changes are
+// validated against the latest status. Canceling an order that was already
+// paid, or moving it to refunded, refunds what is left of its captured
+// payments through provider.
+func transitionOrder(tx *gorm.DB, provider payments.Provider, order *models.Order, status string, actor string, changedByID *uint, note string) error {
+	var locked models.Order
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&locked, order.ID).Error; err != nil {
+		return err
+	}
+	if !models.CanTransitionOrder(locked.Status, status, actor) {
+		return fmt.Errorf("%w: %s cannot move an order from %s to %s",
+			models.ErrInvalidOrderTransition, actor, locked.Status, status)
+	}
+
+	switch status {
+	case models.OrderPaid:
+		if err := commitReservations(tx, locked.ID); err != nil {
+			return err
+		}
+	case models.OrderCanceled:
+		if err := releaseOrderStock(tx, locked.ID); err != nil {
+			return err
+		}
+	}
+
+	from := locked.Status
+	now := time.Now()
+	if err := tx.Model(&locked).Updates(map[string]interface{}{
+		"status": status, "updated_at": now,
+	}).Error; err != nil {
+		return err
+	}
+	locked.Status = status
+	locked.UpdatedAt = now
+	if err := tx.Create(&models.OrderStatusHistory{
+		OrderID:     locked.ID,
+		FromStatus:  from,
+		ToStatus:    status,
+		Actor:       actor,
+		ChangedByID: changedByID,
+		Note:        note,
+		CreatedAt:   now,
+	}).Error; err != nil {
+		return err
+	}
+
+	if status == models.OrderRefunded || (status == models.OrderCanceled && from != models.OrderPending) {
+		if err := refundOrderPayments(tx, provider, locked.ID); err != nil {
+			return err
+		}
+	}
+
+	*order = locked
+	return nil
+}
+
+// refundOrderPayments refunds whatever is left on each of the order's
+// captured payments.
+func refundOrderPayments(tx *gorm.DB, provider payments.Provider, orderID uint) error {
+	var captured []models.Payment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("order_id = ? AND status = ?", orderID, models.PaymentSucceeded).
+		Order("id").Find(&captured).Error; err != nil {
+		return err
+	}
+	for i := range captured {
+		left := roundMoney(captured[i].Amount - captured[i].RefundedAmount)
+		if left <= 0 {
+			continue
+		}
+		if err := refundPayment(tx, provider, &captured[i], left, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refundPayment sends amount back on a captured payment and records the
+// refund, optionally against the return request it settles.
+func refundPayment(tx *gorm.DB, provider payments.Provider, payment *models.Payment, amount float64, returnRequestID *uint) error {
+	if provider == nil {
+		return errNoPaymentProvider
+	}
+	if amount > roundMoney(payment.Amount-payment.RefundedAmount) {
+		return errRefundTooLarge
+	}
+
+	payment.RefundedAmount = roundMoney(payment.RefundedAmount + amount)
+	if payment.RefundedAmount >= payment.Amount {
+		payment.Status = models.PaymentRefunded
+	}
+	if err := tx.Model(payment).Updates(map[string]interface{}{
+		"refunded_amount": payment.RefundedAmount, "status": payment.Status,
+	}).Error; err != nil {
+		return err
+	}
+	// Call the provider last so a failed write above never leaves money
+	// refunded without a record of it.
+	refund, err := provider.Refund(payment.IntentID, toMinorUnits(amount))
+	if err != nil {
+		return err
+	}
+	return tx.Create(&models.Refund{
+		PaymentID:        payment.ID,
+		ReturnRequestID:  returnRequestID,
+		ProviderRefundID: refund.ID,
+		Amount:           amount,
+	}).Error
+}
+
+// reserveStock holds stock for every line of order until expiresAt, failing
+// with ErrOutOfStock if any item doesn't have enough unreserved units left.
+// Each hold is a conditional update, so concurrent checkouts can't reserve
+// the same unit twice; rows are touched in item order to keep concurrent
+// checkouts from deadlocking each other.
+func reserveStock(tx *gorm.DB, order *models.Order, expiresAt time.Time) error {
+	now := time.Now()
+	for _, line := range sortedByItem(order.Lines) {
+		result := tx.Model(&models.Item{}).
+			Where("id = ? AND stock - reserved >= ?", line.ItemID, line.Quantity).
+			Update("reserved", gorm.Expr("reserved + ?", line.Quantity))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("%w: %s", models.ErrOutOfStock, line.ItemName)
+		}
+		if err := tx.Create(&models.StockReservation{
+			OrderID:   order.ID,
+			ItemID:    line.ItemID,
+			Quantity:  line.Quantity,
+			ExpiresAt: expiresAt,
+			CreatedAt: now,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// commitReservations turns the order's active reservations into a permanent
+// stock decrement.
+func commitReservations(tx *gorm.DB, orderID uint) error {
+	reservations, err := orderReservations(tx, orderID)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, reservation := range reservations {
+		if !reservation.Active() {
+			continue
+		}
+		if err := tx.Model(&models.Item{}).Where("id = ?", reservation.ItemID).Updates(map[string]interface{}{
+			"stock":    gorm.Expr("stock - ?", reservation.Quantity),
+			"reserved": gorm.Expr("reserved - ?", reservation.Quantity),
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&reservation).Update("committed_at", now).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseOrderStock gives back everything a canceled order was holding:
+// active reservations are released and committed ones are restocked.
+func releaseOrderStock(tx *gorm.DB, orderID uint) error {
+	reservations, err := orderReservations(tx, orderID)
+	if err != nil {
+		return err
+	}
+	if len(reservations) == 0 {
+		// Orders placed before reservations existed took stock directly.
+		var lines []models.OrderLine
+		if err := tx.Where("order_id = ?", orderID).Find(&lines).Error; err != nil {
+			return err
+		}
+		return returnStock(tx, lines)
+	}
+
+	now := time.Now()
+	for _, reservation := range reservations {
+		switch {
+		case reservation.CommittedAt != nil:
+			if err := tx.Model(&models.Item{}).Where("id = ?", reservation.ItemID).
+				Update("stock", gorm.Expr("stock + ?", reservation.Quantity)).Error; err != nil {
+				return err
+			}
+		case reservation.ReleasedAt == nil:
+			if err := tx.Model(&models.Item{}).Where("id = ?", reservation.ItemID).
+				Update("reserved", gorm.Expr("reserved - ?", reservation.Quantity)).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&reservation).Update("released_at", now).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func orderReservations(tx *gorm.DB, orderID uint) ([]models.StockReservation, error) {
+	var reservations []models.StockReservation
+	err := tx.Where("order_id = ?", orderID).Order("item_id").Find(&reservations).Error
+	return reservations, err
+}
+
+// returnStock puts the quantities of lines back on the shelf. Items that were
+// deleted in the meantime are skipped.
+func returnStock(tx *gorm.DB, lines []models.OrderLine) error {
+	for _, line := range sortedByItem(lines) {
+		if err := tx.Model(&models.Item{}).Where("id = ?", line.ItemID).
+			Update("stock", gorm.Expr("stock + ?", line.Quantity)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sortedByItem(lines []models.OrderLine) []models.OrderLine {
+	sorted := append([]models.OrderLine(nil), lines...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ItemID < sorted[j].ItemID })
+	return sorted
+}
+
+// respondOrderError maps lifecycle and stock errors to 409 and anything else
+// to 400.
+func respondOrderError(ctx *gin.Context, err error) {
+	if errors.Is(err, models.ErrInvalidOrderTransition) || errors.Is(err, models.ErrOutOfStock) {
+		ctx.JSON(http.StatusConflict, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+}
+
+func orderTimeline(tx *gorm.DB, orderID uint) []models.OrderStatusHistory {
+	timeline := []models.OrderStatusHistory{}
+	tx.Where("order_id = ?", orderID).Order("created_at, id").Find(&timeline)
+	return timeline
+}
@@ -0,0 +1,282 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/gmkanat/Go-Shop/initializers"
+	"github.com/gmkanat/Go-Shop/models"
+	"github.com/gmkanat/Go-Shop/payments"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var errOrderNotPayable = errors.New("only pending orders can be paid")
+
+type PaymentController struct {
+	DB       *gorm.DB
+	Provider payments.Provider
+}
+
+func NewPaymentController(DB *gorm.DB, provider payments.Provider) PaymentController {
+	return PaymentController{DB, provider}
+}
+
+// PayOrder [...] Start paying for a pending order
+//
+// Returns the provider's client secret the buyer completes the payment with.
+// The order only becomes paid once the provider confirms it via the webhook.
+// An order has at most one open intent: asking again returns the same one.
+func (pc *PaymentController) PayOrder(ctx *gin.Context) {
+	order := ctx.MustGet("currentOrder").(models.Order)
+
+	config, _ := initializers.LoadConfig(".")
+	currency := strings.ToLower(config.PaymentCurrency)
+	if currency == "" {
+		currency = "usd"
+	}
+
+	var payment models.Payment
+	created := false
+	err := pc.DB.Transaction(func(tx *gorm.DB) error {
+		// The order row lock serialises concurrent attempts to pay it.
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, order.ID).Error; err != nil {
+			return err
+		}
+		if order.Status != models.OrderPending {
+			return errOrderNotPayable
+		}
+		err := tx.Where("order_id = ? AND provider = ? AND status = ? AND amount = ?",
+			order.ID, pc.Provider.Name(), models.PaymentPending, order.Total).
+			Order("id DESC").First(&payment).Error
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		intent, err := pc.Provider.CreateIntent(toMinorUnits(order.Total), currency, fmt.Sprintf("order-%d", order.ID))
+		if err != nil {
+			return err
+		}
+		payment = models.Payment{
+			OrderID:      order.ID,
+			Provider:     pc.Provider.Name(),
+			IntentID:     intent.ID,
+			ClientSecret: intent.ClientSecret,
+			Amount:       order.Total,
+			Currency:     intent.Currency,
+			Status:       models.PaymentPending,
+		}
+		created = true
+		return tx.Create(&payment).Error
+	})
+	if errors.Is(err, errOrderNotPayable) {
+		ctx.JSON(http.StatusConflict, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	response := newPaymentResponse(payment)
+	response.ClientSecret = payment.ClientSecret
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	ctx.JSON(status, gin.H{"status": "success", "payment": response})
+}
+
+// ListOrderPayments [...] Payments made for an order
+func (pc *PaymentController) ListOrderPayments(ctx *gin.Context) {
+	order := ctx.MustGet("currentOrder").(models.Order)
+
+	var payments []models.Payment
+	pc.DB.Where("order_id = ?", order.ID).Order("id").Find(&payments)
+	response := []models.PaymentResponse{}
+	for _, payment := range payments {
+		response = append(response, newPaymentResponse(payment))
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "payments": response})
+}
+
+// Webhook [...] Payment provider notifications
+func (pc *PaymentController) Webhook(ctx *gin.Context) {
+	payload, err := io.ReadAll(ctx.Request.Body)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+	event, err := pc.Provider.VerifyWebhook(payload, ctx.GetHeader("Payment-Signature"))
+	if errors.Is(err, payments.ErrInvalidSignature) {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+	pc.respondEvent(ctx, event)
+}
+
+// MockPay [...] Complete or decline a mock payment without a real gateway
+//
+// Only registered when the mock provider is configured and
+// PAYMENT_MOCK_ROUTES is on.
+func (pc *PaymentController) MockPay(ctx *gin.Context) {
+	mock, ok := pc.Provider.(*payments.MockProvider)
+	if !ok {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "mock payments are disabled"})
+		return
+	}
+	simulate := mock.Pay
+	if ctx.Query("outcome") == "decline" {
+		simulate = mock.Decline
+	}
+	payload, signature, err := simulate(ctx.Param("intent_id"))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+	event, err := mock.VerifyWebhook(payload, signature)
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	pc.respondEvent(ctx, event)
+}
+
+func (pc *PaymentController) respondEvent(ctx *gin.Context, event payments.Event) {
+	payment, err := pc.handleEvent(event)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "unknown payment intent"})
+		return
+	}
+	if err != nil {
+		// Let the provider retry later.
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "payment": newPaymentResponse(payment)})
+}
+
+// handleEvent applies a verified webhook event. Each event is processed at
+// most once and the payment row is locked while it is, so redelivered and
+// concurrent notifications for the same intent are harmless.
+//
+// An authorized payment is only captured if its order is still pending and
+// the authorized amount matches the payment; otherwise (e.g. the order's
+// reservation expired) the authorization is left to lapse and the payment is
+// marked canceled. A payment the provider already captured is refunded
+// instead, including one that arrives after the payment was marked failed or
+// canceled, so the buyer is never charged for an order it doesn't pay.
+func (pc *PaymentController) handleEvent(event payments.Event) (models.Payment, error) {
+	var payment models.Payment
+	err := pc.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("provider = ? AND intent_id = ?", pc.Provider.Name(), event.IntentID).
+			First(&payment).Error; err != nil {
+			return err
+		}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.PaymentEvent{
+			Provider:  pc.Provider.Name(),
+			EventID:   event.ID,
+			Type:      event.Type,
+			CreatedAt: time.Now(),
+		})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		captured := event.Type == payments.EventPaymentSucceeded
+
+		switch payment.Status {
+		case models.PaymentPending:
+		case models.PaymentFailed, models.PaymentCanceled:
+			if captured {
+				return pc.refundCaptured(tx, &payment, event)
+			}
+			return nil
+		default:
+			return nil
+		}
+
+		switch event.Type {
+		case payments.EventPaymentFailed:
+			payment.Status = models.PaymentFailed
+		case payments.EventPaymentAuthorized, payments.EventPaymentSucceeded:
+			var order models.Order
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, payment.OrderID).Error; err != nil {
+				return err
+			}
+			if event.Amount != toMinorUnits(payment.Amount) {
+				note := fmt.Sprintf("payment %s rejected: provider reported %d, expected %d",
+					payment.IntentID, event.Amount, toMinorUnits(payment.Amount))
+				if err := recordOrderEvent(tx, order, models.OrderActorSystem, nil, note); err != nil {
+					return err
+				}
+				if captured {
+					return pc.refundCaptured(tx, &payment, event)
+				}
+				payment.Status = models.PaymentCanceled
+				break
+			}
+			if order.Status != models.OrderPending {
+				if captured {
+					return pc.refundCaptured(tx, &payment, event)
+				}
+				payment.Status = models.PaymentCanceled
+				break
+			}
+			if event.Type == payments.EventPaymentAuthorized {
+				if _, err := pc.Provider.Capture(payment.IntentID); err != nil {
+					return err
+				}
+			}
+			payment.Status = models.PaymentSucceeded
+			note := fmt.Sprintf("paid via %s (%s)", payment.Provider, payment.IntentID)
+			if err := transitionOrder(tx, pc.Provider, &order, models.OrderPaid, models.OrderActorSystem, nil, note); err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+		return tx.Model(&payment).Update("status", payment.Status).Error
+	})
+	return payment, err
+}
+
+// refundCaptured gives back money the provider captured for a payment that
+// won't pay its order. The payment is first set to what was actually
+// captured, which may differ from what was asked for.
+func (pc *PaymentController) refundCaptured(tx *gorm.DB, payment *models.Payment, event payments.Event) error {
+	payment.Status = models.PaymentSucceeded
+	payment.Amount = float64(event.Amount) / 100
+	if err := tx.Model(payment).Updates(map[string]interface{}{
+		"status": payment.Status, "amount": payment.Amount,
+	}).Error; err != nil {
+		return err
+	}
+	return refundPayment(tx, pc.Provider, payment, payment.Amount, nil)
+}
+
+func newPaymentResponse(payment models.Payment) models.PaymentResponse {
+	return models.PaymentResponse{
+		ID:       payment.ID,
+		OrderID:  payment.OrderID,
+		Provider: payment.Provider,
+		IntentID: payment.IntentID,
+		Amount:   payment.Amount,
+		Currency: payment.Currency,
+		Status:   payment.Status,
+	}
+}
+
+// toMinorUnits converts an amount to cents.
+func toMinorUnits(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
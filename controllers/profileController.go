@@ -0,0 +1,224 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/gmkanat/Go-Shop/initializers"
+	"github.com/gmkanat/Go-Shop/mailer"
+	"github.com/gmkanat/Go-Shop/models"
+	"github.com/gmkanat/Go-Shop/utils"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	errInvalidEmailChange = errors.New("invalid or expired confirmation link")
+	errEmailTaken         = errors.New("email is already in use")
+)
+
+// UpdateMe [...] Update profile fields of the current user
+func (uc *UserController) UpdateMe(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	var payload *models.UpdateProfileInput
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+
+	currentUser.Name = strings.TrimSpace(payload.Name)
+	currentUser.UpdatedAt = time.Now()
+	result := uc.DB.Model(&currentUser).Updates(map[string]interface{}{
+		"name": currentUser.Name, "updated_at": currentUser.UpdatedAt,
+	})
+	if result.Error != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": result.Error.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"status": "success", "data": gin.H{"user": newUserResponse(currentUser)},
+	})
+}
+
+// ChangePassword [...] Change password and sign out every other session
+func (uc *UserController) ChangePassword(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+	claims := ctx.MustGet("currentToken").(*utils.TokenClaims)
+
+	var payload *models.ChangePasswordInput
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+
+	if err := utils.VerifyPassword(currentUser.Password, payload.CurrentPassword); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "Current password is incorrect"})
+		return
+	}
+	if payload.Password != payload.PasswordConfirm {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "Passwords do not match"})
+		return
+	}
+
+	hashedPassword, err := utils.HashPassword(payload.Password)
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	err = uc.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&currentUser).Updates(map[string]interface{}{
+			"password": hashedPassword, "updated_at": time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+		revokeOtherSessions(tx, currentUser.ID, sessionIDForToken(tx, claims))
+		return nil
+	})
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": "Something bad happened"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "message": "Password changed, other sessions were signed out"})
+}
+
+// RequestEmailChange [...] Send a confirmation link to the new address
+func (uc *UserController) RequestEmailChange(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	var payload *models.ChangeEmailInput
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+
+	if err := utils.VerifyPassword(currentUser.Password, payload.Password); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "Password is incorrect"})
+		return
+	}
+
+	newEmail := strings.ToLower(strings.TrimSpace(payload.Email))
+	if newEmail == currentUser.Email {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "This is already your email"})
+		return
+	}
+	var taken int64
+	uc.DB.Model(&models.User{}).Where("email = ?", newEmail).Count(&taken)
+	if taken > 0 {
+		ctx.JSON(http.StatusConflict, gin.H{"status": "fail", "message": errEmailTaken.Error()})
+		return
+	}
+
+	token, err := utils.GenerateOpaqueToken(32)
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+
+	config, _ := initializers.LoadConfig(".")
+	now := time.Now()
+	err = uc.DB.Transaction(func(tx *gorm.DB) error {
+		// Only the latest request can be confirmed.
+		if err := tx.Model(&models.EmailChangeRequest{}).
+			Where("user_id = ? AND used_at IS NULL", currentUser.ID).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.EmailChangeRequest{
+			UserID:    currentUser.ID,
+			NewEmail:  newEmail,
+			TokenHash: utils.HashToken(token),
+			ExpiresAt: now.Add(config.EmailVerifyExpiresIn),
+			CreatedAt: now,
+		}).Error
+	})
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": "Something bad happened"})
+		return
+	}
+
+	link := config.AppURL + "/api/users/email/confirm?token=" + url.QueryEscape(token)
+	if err := uc.Mailer.Send(mailer.Message{
+		To:      newEmail,
+		Subject: "Confirm your new Go-Shop email",
+		Body: "Hi " + currentUser.Name + ",\n\n" +
+			"Please confirm this address for your Go-Shop account by opening the link below:\n\n" +
+			link + "\n\n" +
+			"The link expires in " + config.EmailVerifyExpiresIn.String() + ".\n",
+	}); err != nil {
+		log.Println("could not send email change confirmation:", err)
+	}
+	if err := uc.Mailer.Send(mailer.Message{
+		To:      currentUser.Email,
+		Subject: "Your Go-Shop email is about to change",
+		Body: "Hi " + currentUser.Name + ",\n\n" +
+			"Someone asked to change the email of your account to " + newEmail + ". " +
+			"If this wasn't you, change your password right away.\n",
+	}); err != nil {
+		log.Println("could not send email change notice:", err)
+	}
+
+	ctx.JSON(http.StatusAccepted, gin.H{
+		"status": "success", "message": "We sent a confirmation link to " + newEmail,
+	})
+}
+
+// ConfirmEmailChange [...] Apply a pending email change from the emailed link
+func (uc *UserController) ConfirmEmailChange(ctx *gin.Context) {
+	err := uc.DB.Transaction(func(tx *gorm.DB) error {
+		var request models.EmailChangeRequest
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&request, "token_hash = ?", utils.HashToken(ctx.Query("token")))
+		if result.Error != nil || request.UsedAt != nil || time.Now().After(request.ExpiresAt) {
+			return errInvalidEmailChange
+		}
+
+		now := time.Now()
+		if err := tx.Model(&request).Update("used_at", now).Error; err != nil {
+			return err
+		}
+
+		var taken int64
+		tx.Model(&models.User{}).Where("email = ? AND id <> ?", request.NewEmail, request.UserID).Count(&taken)
+		if taken > 0 {
+			return errEmailTaken
+		}
+
+		// The unique index on users.email still guards against a race with
+		// a signup using the same address.
+		if err := tx.Model(&models.User{}).Where("id = ?", request.UserID).Updates(map[string]interface{}{
+			"email": request.NewEmail, "email_verified_at": now, "updated_at": now,
+		}).Error; err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return errEmailTaken
+			}
+			return err
+		}
+
+		// Reset links mailed to the old address must not outlive the change.
+		return tx.Where("user_id = ? AND used_at IS NULL", request.UserID).
+			Delete(&models.PasswordResetToken{}).Error
+	})
+	if errors.Is(err, errInvalidEmailChange) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+	if errors.Is(err, errEmailTaken) {
+		ctx.JSON(http.StatusConflict, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": "Something bad happened"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "message": "Email changed"})
+}
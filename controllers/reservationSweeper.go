@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gmkanat/Go-Shop/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log"
+	"time"
+)
+
+// StartReservationSweeper cancels pending orders whose stock reservations
+// have expired, every interval, until the process exits. Several instances
+// can sweep the same database: each order is handled under its row lock and
+// skipped if another instance or a payment got to it first.
+func StartReservationSweeper(DB *gorm.DB, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			canceled, err := SweepExpiredReservations(DB)
+			if err != nil {
+				log.Println("reservation sweep failed:", err)
+			}
+			if canceled > 0 {
+				log.Printf("reservation sweep canceled %d unpaid orders", canceled)
+			}
+		}
+	}()
+}
+
+// SweepExpiredReservations cancels every pending order that still holds an
+// expired reservation and returns how many it canceled. An order that fails
+// to cancel is skipped so it can't hold up the rest; the failures are
+// returned together for the caller to log.
+func SweepExpiredReservations(DB *gorm.DB) (int, error) {
+	var orderIDs []uint
+	err := DB.Model(&models.StockReservation{}).
+		Where("expires_at <= ? AND committed_at IS NULL AND released_at IS NULL", time.Now()).
+		Distinct().Pluck("order_id", &orderIDs).Error
+	if err != nil {
+		return 0, err
+	}
+
+	canceled := 0
+	var errs []error
+	for _, orderID := range orderIDs {
+		done := false
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			var order models.Order
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderID).Error; err != nil {
+				return err
+			}
+			if order.Status != models.OrderPending {
+				return nil
+			}
+			done = true
+			// Pending orders have nothing captured, so no provider is needed.
+			return transitionOrder(tx, nil, &order, models.OrderCanceled, models.OrderActorSystem, nil, "stock reservation expired")
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("order %d: %w", orderID, err))
+			continue
+		}
+		if done {
+			canceled++
+		}
+	}
+	return canceled, errors.Join(errs...)
+}
@@ -0,0 +1,334 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/gmkanat/Go-Shop/models"
+	"github.com/gmkanat/Go-Shop/payments"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"net/http"
+	"sort"
+	"time"
+)
+
+var (
+	errReturnNotAllowed  = errors.New("only delivered orders can be returned")
+	errReturnOpen        = errors.New("this order already has an open return request")
+	errReturnQuantity    = errors.New("return quantity exceeds what is left to return")
+	errReturnLine        = errors.New("order line does not belong to this order")
+	errReturnReviewed    = errors.New("return request was already reviewed")
+	errReturnNotYours    = errors.New("return contains items sold by another seller")
+	errRefundTooLarge    = errors.New("refund exceeds the amount left on the payment")
+	errRefundOverValue   = errors.New("refund exceeds the value of the returned items")
+	errNoCapturedPayment = errors.New("order has no captured payment to refund")
+)
+
+type ReturnController struct {
+	DB       *gorm.DB
+	Provider payments.Provider
+}
+
+func NewReturnController(DB *gorm.DB, provider payments.Provider) ReturnController {
+	return ReturnController{DB, provider}
+}
+
+// RequestReturn [...] Open a return request for a delivered order
+//
+// Each seller reviews and refunds only their own goods, so a return of lines
+// from several sellers is opened as one request per seller.
+func (rc *ReturnController) RequestReturn(ctx *gin.Context) {
+	order := ctx.MustGet("currentOrder").(models.Order)
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	var payload *models.ReturnRequestInput
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+
+	var requests []models.ReturnRequest
+	err := rc.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, order.ID).Error; err != nil {
+			return err
+		}
+		if order.Status != models.OrderDelivered {
+			return errReturnNotAllowed
+		}
+		var open int64
+		tx.Model(&models.ReturnRequest{}).Where("order_id = ? AND status = ?", order.ID, models.ReturnRequested).Count(&open)
+		if open > 0 {
+			return errReturnOpen
+		}
+
+		lines, err := returnableLines(tx, order.ID)
+		if err != nil {
+			return err
+		}
+		bySeller := map[uint]int{}
+		add := func(line returnableLine, quantity int) {
+			i, ok := bySeller[line.SellerID]
+			if !ok {
+				i = len(requests)
+				bySeller[line.SellerID] = i
+				request := models.ReturnRequest{
+					OrderID: order.ID,
+					UserID:  currentUser.ID,
+					Status:  models.ReturnRequested,
+					Reason:  payload.Reason,
+				}
+				for _, url := range payload.Photos {
+					request.Photos = append(request.Photos, models.ReturnPhoto{URL: url})
+				}
+				requests = append(requests, request)
+			}
+			requests[i].Lines = append(requests[i].Lines, models.ReturnLine{OrderLineID: line.ID, ItemID: line.ItemID, Quantity: quantity})
+		}
+		if len(payload.Lines) == 0 {
+			ids := make([]uint, 0, len(lines))
+			for id := range lines {
+				ids = append(ids, id)
+			}
+			sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+			for _, id := range ids {
+				if line := lines[id]; line.left > 0 {
+					add(line, line.left)
+				}
+			}
+		}
+		for _, input := range payload.Lines {
+			line, ok := lines[input.OrderLineID]
+			if !ok {
+				return errReturnLine
+			}
+			if input.Quantity > line.left {
+				return errReturnQuantity
+			}
+			line.left -= input.Quantity
+			lines[input.OrderLineID] = line
+			add(line, input.Quantity)
+		}
+		if len(requests) == 0 {
+			return errReturnQuantity
+		}
+
+		for i := range requests {
+			if err := tx.Create(&requests[i]).Error; err != nil {
+				return err
+			}
+			if err := recordOrderEvent(tx, order, models.OrderActorBuyer, &currentUser.ID,
+				fmt.Sprintf("return #%d requested: %s", requests[i].ID, requests[i].Reason)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		respondReturnError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, gin.H{"status": "success", "returns": requests})
+}
+
+// ListReturns [...] Return requests of an order
+func (rc *ReturnController) ListReturns(ctx *gin.Context) {
+	order := ctx.MustGet("currentOrder").(models.Order)
+
+	requests := []models.ReturnRequest{}
+	rc.DB.Preload("Lines").Preload("Photos").Where("order_id = ?", order.ID).Order("id").Find(&requests)
+	var refunds []models.Refund
+	rc.DB.Joins("JOIN payments ON payments.id = refunds.payment_id").
+		Where("payments.order_id = ?", order.ID).Order("refunds.id").Find(&refunds)
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "returns": requests, "refunds": refunds})
+}
+
+// ApproveReturn [...] Accept a return, restock its items and refund the buyer
+//
+// Once the refunds cover the price of every item the order moves to
+// refunded, which also refunds its shipping; smaller refunds are partial and
+// leave it delivered.
+func (rc *ReturnController) ApproveReturn(ctx *gin.Context) {
+	rc.reviewReturn(ctx, true)
+}
+
+// RejectReturn [...] Decline a return request
+func (rc *ReturnController) RejectReturn(ctx *gin.Context) {
+	rc.reviewReturn(ctx, false)
+}
+
+func (rc *ReturnController) reviewReturn(ctx *gin.Context, approve bool) {
+	order := ctx.MustGet("currentOrder").(models.Order)
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	var payload models.ReturnReviewInput
+	if ctx.Request.ContentLength > 0 {
+		if err := ctx.ShouldBindJSON(&payload); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+			return
+		}
+	}
+
+	var request models.ReturnRequest
+	err := rc.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, order.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND order_id = ?", ctx.Param("return_id"), order.ID).First(&request).Error; err != nil {
+			return err
+		}
+		if request.Status != models.ReturnRequested {
+			return errReturnReviewed
+		}
+		if err := tx.Where("return_request_id = ?", request.ID).Find(&request.Lines).Error; err != nil {
+			return err
+		}
+		value, err := returnValue(tx, request.Lines, currentUser)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		request.SellerNote = payload.Note
+		request.ResolvedByID = &currentUser.ID
+		request.ResolvedAt = &now
+		if !approve {
+			request.Status = models.ReturnRejected
+			if err := tx.Save(&request).Error; err != nil {
+				return err
+			}
+			return recordOrderEvent(tx, order, models.OrderActorSeller, &currentUser.ID,
+				fmt.Sprintf("return #%d rejected: %s", request.ID, payload.Note))
+		}
+
+		request.Status = models.ReturnApproved
+		request.RefundAmount = value
+		if payload.RefundAmount != nil {
+			request.RefundAmount = roundMoney(*payload.RefundAmount)
+			// An override may only lower the refund, never reach into the
+			// rest of the payment (other lines, other sellers' goods).
+			if request.RefundAmount > value {
+				return errRefundOverValue
+			}
+		}
+		if err := tx.Save(&request).Error; err != nil {
+			return err
+		}
+		var lines []models.OrderLine
+		for _, line := range request.Lines {
+			lines = append(lines, models.OrderLine{ItemID: line.ItemID, Quantity: line.Quantity})
+		}
+		if err := returnStock(tx, lines); err != nil {
+			return err
+		}
+		refunded, err := rc.refund(tx, order, &request)
+		if err != nil {
+			return err
+		}
+
+		note := fmt.Sprintf("return #%d approved, %.2f refunded", request.ID, request.RefundAmount)
+		// Shipping isn't part of any return, so compare against the items.
+		if refunded >= order.Subtotal && order.Status != models.OrderRefunded {
+			return transitionOrder(tx, rc.Provider, &order, models.OrderRefunded, models.OrderActorSeller, &currentUser.ID, note)
+		}
+		return recordOrderEvent(tx, order, models.OrderActorSeller, &currentUser.ID, note)
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "return request not found"})
+		return
+	}
+	if err != nil {
+		respondReturnError(ctx, err)
+		return
+	}
+	rc.DB.Preload("Lines").Preload("Photos").First(&request, request.ID)
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "return": request})
+}
+
+// refund sends request.RefundAmount back on the order's captured payment
+// and returns the total refunded on the order so far.
+func (rc *ReturnController) refund(tx *gorm.DB, order models.Order, request *models.ReturnRequest) (float64, error) {
+	var payment models.Payment
+	tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("order_id = ? AND status IN ?", order.ID, []string{models.PaymentSucceeded, models.PaymentRefunded}).
+		Order("id").First(&payment)
+	if request.RefundAmount == 0 {
+		return payment.RefundedAmount, nil
+	}
+	if payment.ID == 0 {
+		return 0, errNoCapturedPayment
+	}
+	if err := refundPayment(tx, rc.Provider, &payment, request.RefundAmount, &request.ID); err != nil {
+		return 0, err
+	}
+	return payment.RefundedAmount, nil
+}
+
+type returnableLine struct {
+	models.OrderLine
+	left int
+}
+
+// returnableLines returns the order's lines keyed by id with how many units
+// are not yet covered by an open or approved return.
+func returnableLines(tx *gorm.DB, orderID uint) (map[uint]returnableLine, error) {
+	var lines []models.OrderLine
+	if err := tx.Where("order_id = ?", orderID).Find(&lines).Error; err != nil {
+		return nil, err
+	}
+	var returned []struct {
+		OrderLineID uint
+		Quantity    int
+	}
+	err := tx.Model(&models.ReturnLine{}).
+		Select("return_lines.order_line_id, SUM(return_lines.quantity) as quantity").
+		Joins("JOIN return_requests ON return_requests.id = return_lines.return_request_id").
+		Where("return_requests.order_id = ? AND return_requests.status IN ?", orderID,
+			[]string{models.ReturnRequested, models.ReturnApproved}).
+		Group("return_lines.order_line_id").Scan(&returned).Error
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[uint]returnableLine{}
+	for _, line := range lines {
+		result[line.ID] = returnableLine{line, line.Quantity}
+	}
+	for _, r := range returned {
+		if line, ok := result[r.OrderLineID]; ok {
+			line.left -= r.Quantity
+			result[r.OrderLineID] = line
+		}
+	}
+	return result, nil
+}
+
+// returnValue is what the returned lines were bought for. Sellers can only
+// review returns of their own items unless they have orders:manage_any.
+func returnValue(tx *gorm.DB, lines []models.ReturnLine, reviewer models.User) (float64, error) {
+	value := 0.0
+	for _, line := range lines {
+		var orderLine models.OrderLine
+		if err := tx.First(&orderLine, line.OrderLineID).Error; err != nil {
+			return 0, err
+		}
+		if orderLine.SellerID != reviewer.ID && !reviewer.Role.HasPermission(models.PermOrdersManageAny) {
+			return 0, errReturnNotYours
+		}
+		value += orderLine.UnitPrice * float64(line.Quantity)
+	}
+	return roundMoney(value), nil
+}
+
+func respondReturnError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errReturnNotYours):
+		ctx.JSON(http.StatusForbidden, gin.H{"status": "fail", "message": err.Error()})
+	case errors.Is(err, errReturnNotAllowed), errors.Is(err, errReturnOpen), errors.Is(err, errReturnReviewed),
+		errors.Is(err, errNoCapturedPayment), errors.Is(err, errRefundTooLarge):
+		ctx.JSON(http.StatusConflict, gin.H{"status": "fail", "message": err.Error()})
+	default:
+		respondOrderError(ctx, err)
+	}
+}
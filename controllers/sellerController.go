@@ -0,0 +1,173 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gmkanat/Go-Shop/middleware"
+	"github.com/gmkanat/Go-Shop/models"
+	"github.com/gmkanat/Go-Shop/payments"
+	"gorm.io/gorm"
+	"net/http"
+)
+
+type SellerController struct {
+	DB       *gorm.DB
+	Provider payments.Provider
+}
+
+func NewSellerController(DB *gorm.DB, provider payments.Provider) SellerController {
+	return SellerController{DB, provider}
+}
+
+// LowStockItems [...] The current seller's items whose unreserved stock is at or below their low-stock threshold
+func (sc *SellerController) LowStockItems(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	items := []models.LowStockItem{}
+	sc.DB.Model(&models.Item{}).
+		Select("id, name, stock, reserved, stock - reserved as available, low_stock_threshold").
+		Where("seller_id = ? AND stock - reserved <= low_stock_threshold", currentUser.ID).
+		Order("available, id").Scan(&items)
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "items": items})
+}
+
+// ListOrders [...] Orders containing the current seller's items, newest first
+//
+// Takes the same status, from, to, page and per_page parameters as
+// GET /users/me/orders.
+func (sc *SellerController) ListOrders(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	query := sc.DB.Model(&models.Order{}).Where(sellerOrdersCondition, currentUser.ID)
+	query, err := filterOrders(ctx, query)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+	query = query.Session(&gorm.Session{})
+	var total int64
+	query.Count(&total)
+
+	pagination := paginate(ctx, total)
+	orders := []models.SellerOrderSummary{}
+	query.Select("orders.id, orders.status, orders.created_at, orders.updated_at, users.name as buyer_name, "+
+		"(SELECT COALESCE(SUM(subtotal), 0) FROM order_lines WHERE order_lines.order_id = orders.id AND order_lines.seller_id = ?) as seller_total, "+
+		"(SELECT COALESCE(SUM(quantity), 0) FROM order_lines WHERE order_lines.order_id = orders.id AND order_lines.seller_id = ?) as seller_item_count",
+		currentUser.ID, currentUser.ID).
+		Joins("LEFT JOIN users ON users.id = orders.user_id").
+		Order("orders.created_at DESC, orders.id DESC").
+		Offset((pagination.Page - 1) * pagination.PerPage).Limit(pagination.PerPage).
+		Scan(&orders)
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "orders": orders, "pagination": pagination})
+}
+
+// OrderStats [...] Number of the current seller's orders in each status
+func (sc *SellerController) OrderStats(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	sc.DB.Model(&models.Order{}).Select("status, COUNT(*) as count").
+		Where(sellerOrdersCondition, currentUser.ID).Group("status").Scan(&rows)
+
+	counts := map[string]int64{}
+	for _, status := range models.OrderStatuses {
+		counts[status] = 0
+	}
+	var total int64
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+		total += row.Count
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "counts": counts, "total": total})
+}
+
+// BulkUpdateOrderStatus [...] Move several orders to the same status
+//
+// Every order is changed in its own transaction, so one order that can't be
+// changed doesn't hold back the rest; the result reports each order. Without
+// orders:manage_any a seller can only change orders whose every line they
+// sold.
+func (sc *SellerController) BulkUpdateOrderStatus(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	var payload *models.BulkOrderStatusInput
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+
+	manageAny := currentUser.Role.HasPermission(models.PermOrdersManageAny)
+	results := []models.BulkOrderStatusResult{}
+	updated := 0
+	for _, orderID := range payload.OrderIDs {
+		result := models.BulkOrderStatusResult{OrderID: orderID}
+		var order models.Order
+		sc.DB.First(&order, orderID)
+		switch {
+		case order.ID == 0:
+			result.Error = "order not found"
+		case !manageAny && !middleware.SellsInOrder(sc.DB, order.ID, currentUser.ID):
+			result.Error = "You have not access"
+		case !manageAny && sellsOtherLines(sc.DB, order.ID, currentUser.ID):
+			// A status applies to the whole order, so one seller can't move
+			// other sellers' lines along with their own.
+			result.Error = "order has items from other sellers"
+		default:
+			err := sc.DB.Transaction(func(tx *gorm.DB) error {
+				return transitionOrder(tx, sc.Provider, &order, payload.Status, models.OrderActorSeller, &currentUser.ID, payload.Note)
+			})
+			if err != nil {
+				result.Error = err.Error()
+			} else {
+				result.Status = order.Status
+				updated++
+			}
+		}
+		results = append(results, result)
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "updated": updated, "results": results})
+}
+
+// PackingSlip [...] Lines of an order the current seller has to ship
+func (sc *SellerController) PackingSlip(ctx *gin.Context) {
+	order := ctx.MustGet("currentOrder").(models.Order)
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	var buyer models.User
+	sc.DB.Select("id, name").First(&buyer, order.UserID)
+	slip := models.PackingSlip{
+		OrderID:   order.ID,
+		Status:    order.Status,
+		BuyerName: buyer.Name,
+		Comment:   order.Comment,
+		ShipTo:    order.ShippingAddress,
+		Shipping:  []models.OrderShipment{},
+		Lines:     []models.PackingSlipLine{},
+		CreatedAt: order.CreatedAt,
+	}
+
+	// Admins looking at someone else's order get every line.
+	query := sc.DB.Model(&models.OrderLine{}).Where("order_id = ?", order.ID)
+	shipments := sc.DB.Where("order_id = ?", order.ID)
+	if middleware.SellsInOrder(sc.DB, order.ID, currentUser.ID) {
+		query = query.Where("seller_id = ?", currentUser.ID)
+		shipments = shipments.Where("seller_id = ?", currentUser.ID)
+	}
+	query.Select("item_id, item_name, quantity").Order("id").Scan(&slip.Lines)
+	shipments.Order("id").Find(&slip.Shipping)
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "packing_slip": slip})
+}
+
+const sellerOrdersCondition = "EXISTS (SELECT 1 FROM order_lines WHERE order_lines.order_id = orders.id AND order_lines.seller_id = ?)"
+
+// sellsOtherLines reports whether the order has lines sold by someone other
+// than sellerID.
+func sellsOtherLines(DB *gorm.DB, orderID uint, sellerID uint) bool {
+	var lines int64
+	DB.Model(&models.OrderLine{}).Where("order_id = ? AND seller_id <> ?", orderID, sellerID).Count(&lines)
+	return lines > 0
+}
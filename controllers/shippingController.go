@@ -0,0 +1,245 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/gmkanat/Go-Shop/initializers"
+	"github.com/gmkanat/Go-Shop/models"
+	"github.com/gmkanat/Go-Shop/shipping"
+	"github.com/gmkanat/Go-Shop/utils"
+	"gorm.io/gorm"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const defaultShippingLabel = "Standard shipping"
+
+var errShippingMethodUnavailable = errors.New("shipping method is not available for this order")
+
+// ListShippingMethods [...] Shipping methods of the current seller
+func (sc *SellerController) ListShippingMethods(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	var methods []models.ShippingMethod
+	sc.DB.Where("seller_id = ?", currentUser.ID).Order("id").Find(&methods)
+	response := []models.ShippingMethodResponse{}
+	for _, method := range methods {
+		response = append(response, newShippingMethodResponse(method))
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "shipping_methods": response})
+}
+
+// CreateShippingMethod [...] Add a shipping method for the current seller
+func (sc *SellerController) CreateShippingMethod(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	var payload *models.ShippingMethodInput
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+	method := models.ShippingMethod{SellerID: currentUser.ID, Active: true}
+	if err := applyShippingMethodInput(&method, payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+	if err := sc.DB.Create(&method).Error; err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusCreated, gin.H{"status": "success", "shipping_method": newShippingMethodResponse(method)})
+}
+
+// UpdateShippingMethod [...] Replace a shipping method of the current seller
+func (sc *SellerController) UpdateShippingMethod(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	var payload *models.ShippingMethodInput
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+	var method models.ShippingMethod
+	sc.DB.Where("id = ? AND seller_id = ?", ctx.Param("id"), currentUser.ID).First(&method)
+	if method.ID == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "shipping method not found"})
+		return
+	}
+	if err := applyShippingMethodInput(&method, payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+	if err := sc.DB.Save(&method).Error; err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "shipping_method": newShippingMethodResponse(method)})
+}
+
+// DeleteShippingMethod [...] Remove a shipping method; orders keep their quote
+func (sc *SellerController) DeleteShippingMethod(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	result := sc.DB.Where("id = ? AND seller_id = ?", ctx.Param("id"), currentUser.ID).Delete(&models.ShippingMethod{})
+	if result.Error != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{"status": "error", "message": result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "shipping method not found"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+func applyShippingMethodInput(method *models.ShippingMethod, payload *models.ShippingMethodInput) error {
+	countries := []string{}
+	for _, country := range payload.Countries {
+		code, err := utils.NormalizeCountry(country)
+		if err != nil {
+			return fmt.Errorf("%w: %q", err, country)
+		}
+		countries = append(countries, code)
+	}
+	tiers, err := json.Marshal(payload.Tiers)
+	if err != nil {
+		return err
+	}
+
+	method.Type = payload.Type
+	method.Label = strings.TrimSpace(payload.Label)
+	method.FlatCost = roundMoney(payload.FlatCost)
+	method.FreeOver = roundMoney(payload.FreeOver)
+	method.Tiers = string(tiers)
+	method.Countries = strings.Join(countries, " ")
+	if payload.Active != nil {
+		method.Active = *payload.Active
+	}
+	// Building the provider validates the tier table.
+	_, err = shipping.New(method.ProviderConfig())
+	return err
+}
+
+func newShippingMethodResponse(method models.ShippingMethod) models.ShippingMethodResponse {
+	return models.ShippingMethodResponse{
+		ID:        method.ID,
+		Type:      method.Type,
+		Label:     method.Label,
+		FlatCost:  method.FlatCost,
+		Tiers:     method.TierList(),
+		FreeOver:  method.FreeOver,
+		Countries: method.CountryList(),
+		Active:    method.Active,
+	}
+}
+
+// shippingLine is quantity units of item on their way to the buyer.
+type shippingLine struct {
+	Item     models.Item
+	Quantity int
+}
+
+// shippingOptions quotes every method each seller offers for their part of
+// lines, cheapest first. Sellers without active methods ship at the default
+// rate from the config. A seller with no method delivering to shipTo makes
+// the whole order undeliverable.
+func shippingOptions(tx *gorm.DB, lines []shippingLine, shipTo models.OrderAddress) ([]models.SellerShippingOptions, error) {
+	bySeller := map[uint]*shipping.Shipment{}
+	var sellerIDs []uint
+	for _, line := range lines {
+		shipment, ok := bySeller[line.Item.SellerID]
+		if !ok {
+			shipment = &shipping.Shipment{Country: shipTo.Country, PostalCode: shipTo.PostalCode}
+			bySeller[line.Item.SellerID] = shipment
+			sellerIDs = append(sellerIDs, line.Item.SellerID)
+		}
+		shipment.Parcels = append(shipment.Parcels, shipping.Parcel{
+			WeightGrams: line.Item.WeightGrams,
+			LengthCm:    line.Item.LengthCm,
+			WidthCm:     line.Item.WidthCm,
+			HeightCm:    line.Item.HeightCm,
+			Quantity:    line.Quantity,
+		})
+		shipment.Subtotal = roundMoney(shipment.Subtotal + line.Item.Price*float64(line.Quantity))
+	}
+	sort.Slice(sellerIDs, func(i, j int) bool { return sellerIDs[i] < sellerIDs[j] })
+
+	config, _ := initializers.LoadConfig(".")
+	result := []models.SellerShippingOptions{}
+	for _, sellerID := range sellerIDs {
+		shipment := *bySeller[sellerID]
+		options := models.SellerShippingOptions{SellerID: sellerID, Subtotal: shipment.Subtotal, Options: []models.ShippingOption{}}
+
+		var methods []models.ShippingMethod
+		tx.Where("seller_id = ? AND active", sellerID).Order("id").Find(&methods)
+		if len(methods) == 0 {
+			options.Options = append(options.Options, models.ShippingOption{
+				Label: defaultShippingLabel, Cost: roundMoney(config.ShippingDefaultRate),
+			})
+		}
+		for _, method := range methods {
+			provider, err := shipping.New(method.ProviderConfig())
+			if err != nil {
+				continue
+			}
+			quote, err := provider.Quote(shipment)
+			if err != nil {
+				continue
+			}
+			id := method.ID
+			options.Options = append(options.Options, models.ShippingOption{
+				ShippingMethodID: &id, Label: quote.Label, Cost: roundMoney(quote.Cost),
+			})
+		}
+		if len(options.Options) == 0 {
+			return nil, fmt.Errorf("%w: no shipping from seller %d to %s", shipping.ErrNotDeliverable, sellerID, shipTo.Country)
+		}
+		sort.SliceStable(options.Options, func(i, j int) bool { return options.Options[i].Cost < options.Options[j].Cost })
+		result = append(result, options)
+	}
+	return result, nil
+}
+
+// planShipments picks a shipping method for each seller in lines: the one
+// from chosen that belongs to the seller, or their cheapest option.
+func planShipments(tx *gorm.DB, lines []shippingLine, shipTo models.OrderAddress, chosen []uint) ([]models.OrderShipment, error) {
+	sellers, err := shippingOptions(tx, lines, shipTo)
+	if err != nil {
+		return nil, err
+	}
+	used := map[uint]bool{}
+	var shipments []models.OrderShipment
+	for _, seller := range sellers {
+		picked := seller.Options[0]
+		for _, option := range seller.Options {
+			if option.ShippingMethodID != nil && containsID(chosen, *option.ShippingMethodID) {
+				picked = option
+				used[*option.ShippingMethodID] = true
+			}
+		}
+		shipments = append(shipments, models.OrderShipment{
+			SellerID:         seller.SellerID,
+			ShippingMethodID: picked.ShippingMethodID,
+			Label:            picked.Label,
+			Cost:             picked.Cost,
+		})
+	}
+	for _, id := range chosen {
+		if !used[id] {
+			return nil, fmt.Errorf("%w: %d", errShippingMethodUnavailable, id)
+		}
+	}
+	return shipments, nil
+}
+
+func containsID(ids []uint, id uint) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"time"
+
+	"github.com/gmkanat/Go-Shop/models"
+	"github.com/gmkanat/Go-Shop/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// revokeAccessToken adds jti to the deny-list checked by DeserializeUser.
+func revokeAccessToken(tx *gorm.DB, userID uint, jti string, expiresAt time.Time) error {
+	if jti == "" || time.Now().After(expiresAt) {
+		return nil
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.RevokedToken{
+		JTI:       jti,
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	}).Error
+}
+
+// revokeRefreshTokens revokes the given refresh tokens together with the
+// access tokens that were issued next to them.
+func revokeRefreshTokens(tx *gorm.DB, tokens []models.RefreshToken) error {
+	now := time.Now()
+	for _, token := range tokens {
+		if err := revokeAccessToken(tx, token.UserID, token.AccessTokenID, token.AccessTokenExpiresAt); err != nil {
+			return err
+		}
+		if token.RevokedAt == nil {
+			if err := tx.Model(&token).Update("revoked_at", now).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func revokeRefreshFamily(tx *gorm.DB, familyID string) {
+	var tokens []models.RefreshToken
+	tx.Where("family_id = ?", familyID).Find(&tokens)
+	revokeRefreshTokens(tx, tokens)
+	tx.Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now())
+}
+
+// revokeUserSession revokes sessionID if it belongs to userID and reports
+// whether such a session exists.
+func revokeUserSession(tx *gorm.DB, userID uint, sessionID string) bool {
+	var count int64
+	tx.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND user_id = ?", sessionID, userID).
+		Count(&count)
+	if count == 0 {
+		return false
+	}
+	revokeRefreshFamily(tx, sessionID)
+	return true
+}
+
+func revokeRefreshFamilyByToken(tx *gorm.DB, refreshToken string) {
+	var stored models.RefreshToken
+	if tx.First(&stored, "token_hash = ?", utils.HashToken(refreshToken)).Error != nil {
+		return
+	}
+	revokeRefreshFamily(tx, stored.FamilyID)
+}
+
+// revokeAllUserTokens logs the user out everywhere.
+func revokeAllUserTokens(tx *gorm.DB, userID uint) error {
+	var tokens []models.RefreshToken
+	if err := tx.Where("user_id = ? AND (revoked_at IS NULL OR access_token_expires_at > ?)", userID, time.Now()).
+		Find(&tokens).Error; err != nil {
+		return err
+	}
+	if err := revokeRefreshTokens(tx, tokens); err != nil {
+		return err
+	}
+	return tx.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// revokeOtherSessions logs the user out of every session except keepSessionID.
+func revokeOtherSessions(tx *gorm.DB, userID uint, keepSessionID string) {
+	var tokens []models.RefreshToken
+	tx.Where("user_id = ? AND family_id <> ? AND (revoked_at IS NULL OR access_token_expires_at > ?)",
+		userID, keepSessionID, time.Now()).
+		Find(&tokens)
+	revokeRefreshTokens(tx, tokens)
+	tx.Model(&models.Session{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, keepSessionID).
+		Update("revoked_at", time.Now())
+}
+
+// sessionIDForToken returns the session an access token belongs to, falling
+// back to the refresh token it was issued with for tokens without "sid".
+func sessionIDForToken(tx *gorm.DB, claims *utils.TokenClaims) string {
+	if claims.SessionID != "" {
+		return claims.SessionID
+	}
+	if claims.ID == "" {
+		return ""
+	}
+	var stored models.RefreshToken
+	if tx.Select("family_id").First(&stored, "access_token_id = ?", claims.ID).Error != nil {
+		return ""
+	}
+	return stored.FamilyID
+}
@@ -0,0 +1,275 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/gmkanat/Go-Shop/initializers"
+	"github.com/gmkanat/Go-Shop/models"
+	"github.com/gmkanat/Go-Shop/utils"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	twoFactorChallengePurpose = "2fa_challenge"
+	recoveryCodeCount         = 10
+)
+
+// SetupTwoFactor [...] Generate a TOTP secret to be confirmed with EnableTwoFactor
+func (ac *AuthController) SetupTwoFactor(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+	if currentUser.TOTPEnabledAt != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"status": "fail", "message": "Two-factor authentication is already enabled",
+		})
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{
+			"status": "error", "message": err.Error(),
+		})
+		return
+	}
+
+	if err := ac.DB.Model(&currentUser).Update("totp_secret", secret).Error; err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{
+			"status": "error", "message": "Something bad happened",
+		})
+		return
+	}
+
+	config, _ := initializers.LoadConfig(".")
+	setupResponse := models.TwoFactorSetupResponse{
+		Secret:          secret,
+		ProvisioningURI: utils.TOTPProvisioningURI(config.TOTPIssuer, currentUser.Email, secret),
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "two_factor": setupResponse})
+}
+
+// EnableTwoFactor [...] Confirm the TOTP secret and receive recovery codes
+func (ac *AuthController) EnableTwoFactor(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	var payload *models.TwoFactorCodeInput
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"status": "fail", "message": err.Error(),
+		})
+		return
+	}
+
+	if currentUser.TOTPEnabledAt != nil || currentUser.TOTPSecret == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"status": "fail", "message": "Start two-factor setup first",
+		})
+		return
+	}
+
+	step, ok := utils.ValidateTOTP(currentUser.TOTPSecret, payload.Code, time.Now())
+	if !ok {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"status": "fail", "message": "Invalid two-factor code",
+		})
+		return
+	}
+
+	var codes []string
+	err := ac.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(&currentUser).Updates(map[string]interface{}{
+			"totp_enabled_at": now, "totp_last_step": step,
+		}).Error; err != nil {
+			return err
+		}
+
+		var err error
+		codes, err = replaceRecoveryCodes(tx, currentUser.ID)
+		return err
+	})
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{
+			"status": "error", "message": "Something bad happened",
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "recovery_codes": codes})
+}
+
+// DisableTwoFactor [...] Turn off two-factor authentication
+func (ac *AuthController) DisableTwoFactor(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	var payload *models.TwoFactorDisableInput
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"status": "fail", "message": err.Error(),
+		})
+		return
+	}
+
+	if currentUser.TOTPEnabledAt == nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"status": "fail", "message": "Two-factor authentication is not enabled",
+		})
+		return
+	}
+
+	if err := utils.VerifyPassword(currentUser.Password, payload.Password); err != nil ||
+		!checkSecondFactor(ac.DB, currentUser, payload.Code, payload.Code) {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"status": "fail", "message": "Invalid password or two-factor code",
+		})
+		return
+	}
+
+	err := ac.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&currentUser).Updates(map[string]interface{}{
+			"totp_secret": "", "totp_enabled_at": nil, "totp_last_step": 0,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", currentUser.ID).Delete(&models.RecoveryCode{}).Error
+	})
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{
+			"status": "error", "message": "Something bad happened",
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+// RegenerateRecoveryCodes [...] Replace all recovery codes
+func (ac *AuthController) RegenerateRecoveryCodes(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	var payload *models.TwoFactorCodeInput
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"status": "fail", "message": err.Error(),
+		})
+		return
+	}
+
+	if currentUser.TOTPEnabledAt == nil || !checkSecondFactor(ac.DB, currentUser, payload.Code, "") {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"status": "fail", "message": "Invalid two-factor code",
+		})
+		return
+	}
+
+	var codes []string
+	err := ac.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, currentUser.ID)
+		return err
+	})
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, gin.H{
+			"status": "error", "message": "Something bad happened",
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "recovery_codes": codes})
+}
+
+// VerifyTwoFactorLogin [...] Second login step for accounts with 2FA
+func (ac *AuthController) VerifyTwoFactorLogin(ctx *gin.Context) {
+	var payload *models.TwoFactorLoginInput
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"status": "fail", "message": err.Error(),
+		})
+		return
+	}
+
+	config, _ := initializers.LoadConfig(".")
+
+	claims, err := utils.ValidatePurposeToken(payload.ChallengeToken, twoFactorChallengePurpose, initializers.TokenKeys)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"status": "fail", "message": "Invalid or expired challenge, please log in again",
+		})
+		return
+	}
+
+	var user models.User
+	result := ac.DB.First(&user, "id = ?", fmt.Sprint(claims.Subject))
+	if result.Error != nil || user.TOTPEnabledAt == nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"status": "fail", "message": "Invalid or expired challenge, please log in again",
+		})
+		return
+	}
+
+	if ac.loginLockedOut(ctx, &config, user.Email) {
+		return
+	}
+
+	if !checkSecondFactor(ac.DB, user, payload.Code, payload.RecoveryCode) {
+		ac.recordLoginFailure(ctx, &config)
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"status": "fail", "message": "Invalid two-factor code",
+		})
+		return
+	}
+
+	ac.recordLoginSuccess(&config, user.Email)
+	ac.startSession(ctx, user, &config)
+}
+
+// checkSecondFactor accepts either a TOTP code that has not been used before
+// or an unused recovery code, consuming whichever matched.
+func checkSecondFactor(tx *gorm.DB, user models.User, code string, recoveryCode string) bool {
+	if code != "" {
+		if step, ok := utils.ValidateTOTP(user.TOTPSecret, code, time.Now()); ok {
+			result := tx.Model(&models.User{}).
+				Where("id = ? AND totp_last_step < ?", user.ID, step).
+				Update("totp_last_step", step)
+			if result.Error == nil && result.RowsAffected == 1 {
+				return true
+			}
+		}
+	}
+
+	recoveryCode = strings.ToLower(strings.TrimSpace(recoveryCode))
+	if recoveryCode == "" {
+		return false
+	}
+	result := tx.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, utils.HashToken(recoveryCode)).
+		Update("used_at", time.Now())
+	return result.Error == nil && result.RowsAffected == 1
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := utils.GenerateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		if err := tx.Create(&models.RecoveryCode{
+			UserID:    userID,
+			CodeHash:  utils.HashToken(code),
+			CreatedAt: now,
+		}).Error; err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
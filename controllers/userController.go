@@ -1,50 +1,159 @@
 package controllers
 
 import (
+	"github.com/gmkanat/Go-Shop/mailer"
 	"github.com/gmkanat/Go-Shop/models"
+	"github.com/gmkanat/Go-Shop/payments"
+	"github.com/gmkanat/Go-Shop/utils"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
 type UserController struct {
-	DB *gorm.DB
+	DB       *gorm.DB
+	Mailer   mailer.Mailer
+	Provider payments.Provider
 }
 
-func NewUserController(DB *gorm.DB) UserController {
-	return UserController{DB}
+func NewUserController(DB *gorm.DB, mailer mailer.Mailer, provider payments.Provider) UserController {
+	return UserController{DB, mailer, provider}
 }
 
 func (uc *UserController) GetMe(ctx *gin.Context) {
 	currentUser := ctx.MustGet("currentUser").(models.User)
 
-	userResponse := &models.UserResponse{
-		ID:        currentUser.ID,
-		Name:      currentUser.Name,
-		Email:     currentUser.Email,
-		CreatedAt: currentUser.CreatedAt,
-		UpdatedAt: currentUser.UpdatedAt,
-	}
-
 	ctx.JSON(http.StatusOK, gin.H{
-		"status": "success", "data": gin.H{"user": userResponse},
+		"status": "success", "data": gin.H{"user": newUserResponse(currentUser)},
 	})
 }
 
-func (uc *UserController) CancelOrder(ctx *gin.Context) {
-	order := ctx.MustGet("currentOrder").(models.Order)
+func newUserResponse(user models.User) *models.UserResponse {
+	return &models.UserResponse{
+		ID:            user.ID,
+		Name:          user.Name,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerifiedAt != nil,
+		TwoFactor:     user.TOTPEnabledAt != nil,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+	}
+}
+
+// ListSessions [...] List active sessions of the current user
+func (uc *UserController) ListSessions(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+	claims := ctx.MustGet("currentToken").(*utils.TokenClaims)
 
-	order.Status = "canceled"
+	var sessions []models.Session
+	uc.DB.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", currentUser.ID, time.Now()).
+		Order("last_seen_at DESC").
+		Find(&sessions)
 
-	result := uc.DB.Save(order)
-	if result.Error != nil {
+	sessionsResponse := make([]models.SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		sessionsResponse = append(sessionsResponse, models.SessionResponse{
+			ID:         session.ID,
+			IPAddress:  session.IPAddress,
+			UserAgent:  session.UserAgent,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			Current:    session.ID == claims.SessionID,
+		})
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "sessions": sessionsResponse})
+}
+
+// RevokeSession [...] Revoke one session of the current user
+func (uc *UserController) RevokeSession(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	if !revokeUserSession(uc.DB, currentUser.ID, ctx.Param("id")) {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "session not found"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+// ApplyForSeller [...] Ask an admin to grant the seller role
+func (uc *UserController) ApplyForSeller(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	var payload *models.SellerApplicationInput
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+		return
+	}
+
+	uc.DB.First(&currentUser.Role, currentUser.RoleId)
+	if currentUser.Role.Name != models.RoleBuyer {
+		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": "only buyers can apply to become sellers"})
+		return
+	}
+
+	var pending int64
+	uc.DB.Model(&models.SellerApplication{}).
+		Where("user_id = ? AND status = ?", currentUser.ID, models.SellerApplicationPending).
+		Count(&pending)
+	if pending > 0 {
+		ctx.JSON(http.StatusConflict, gin.H{"status": "fail", "message": "you already have a pending application"})
+		return
+	}
+
+	application := models.SellerApplication{
+		UserID:    currentUser.ID,
+		Message:   payload.Message,
+		Status:    models.SellerApplicationPending,
+		CreatedAt: time.Now(),
+	}
+	if result := uc.DB.Create(&application); result.Error != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"status": "fail", "message": result.Error.Error()})
 		return
 	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"status": "success", "application": application})
+}
+
+// GetSellerApplication [...] Latest seller application of the current user
+func (uc *UserController) GetSellerApplication(ctx *gin.Context) {
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	var application models.SellerApplication
+	result := uc.DB.Where("user_id = ?", currentUser.ID).Order("id DESC").First(&application)
+	if result.Error != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"status": "fail", "message": "no seller application found"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "success", "application": application})
+}
+
+func (uc *UserController) CancelOrder(ctx *gin.Context) {
+	order := ctx.MustGet("currentOrder").(models.Order)
+	currentUser := ctx.MustGet("currentUser").(models.User)
+
+	err := uc.DB.Transaction(func(tx *gorm.DB) error {
+		return transitionOrder(tx, uc.Provider, &order, models.OrderCanceled, models.OrderActorBuyer, &currentUser.ID, "")
+	})
+	if err != nil {
+		respondOrderError(ctx, err)
+		return
+	}
 	NewOrderResponce := models.OrderResponse{
 		ID:     order.ID,
 		Status: order.Status,
 	}
 	ctx.JSON(http.StatusOK, gin.H{"status": "success", "order": NewOrderResponce})
 }
+
+// OrderTimeline [...] Status history of an order
+func (uc *UserController) OrderTimeline(ctx *gin.Context) {
+	order := ctx.MustGet("currentOrder").(models.Order)
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"status": "success", "order_id": order.ID, "order_status": order.Status, "timeline": orderTimeline(uc.DB, order.ID),
+	})
+}
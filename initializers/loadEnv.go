@@ -13,10 +13,55 @@ type Config struct {
 	DBName         string `mapstructure:"POSTGRES_DB"`
 	DBPort         string `mapstructure:"POSTGRES_PORT"`
 	ServerPort     string `mapstructure:"PORT"`
+	AdminEmail     string `mapstructure:"ADMIN_EMAIL"`
 
 	AccessTokenExpiresIn time.Duration `mapstructure:"TOKEN_EXPIRED_IN"`
 	AccessTokenMaxAge    int           `mapstructure:"TOKEN_MAXAGE"`
 	TokenSecret          string        `mapstructure:"TOKEN_SECRET"`
+	TokenAlgorithm       string        `mapstructure:"TOKEN_ALGORITHM"`
+	TokenPrivateKeyPath  string        `mapstructure:"TOKEN_PRIVATE_KEY_PATH"`
+	TokenKeyID           string        `mapstructure:"TOKEN_KEY_ID"`
+	TokenVerifyKeys      []string      `mapstructure:"TOKEN_VERIFY_KEYS"`
+
+	RefreshTokenExpiresIn time.Duration `mapstructure:"REFRESH_TOKEN_EXPIRED_IN"`
+	RefreshTokenMaxAge    int           `mapstructure:"REFRESH_TOKEN_MAXAGE"`
+
+	TOTPIssuer                  string        `mapstructure:"TOTP_ISSUER"`
+	TwoFactorChallengeExpiresIn time.Duration `mapstructure:"TWO_FACTOR_CHALLENGE_EXPIRED_IN"`
+	TwoFactorRequiredRoles      []string      `mapstructure:"TWO_FACTOR_REQUIRED_ROLES"`
+
+	LoginMaxAttempts   int           `mapstructure:"LOGIN_MAX_ATTEMPTS"`
+	LoginIPMaxAttempts int           `mapstructure:"LOGIN_IP_MAX_ATTEMPTS"`
+	LoginAttemptWindow time.Duration `mapstructure:"LOGIN_ATTEMPT_WINDOW"`
+	LoginLockoutBase   time.Duration `mapstructure:"LOGIN_LOCKOUT_BASE"`
+	LoginLockoutMax    time.Duration `mapstructure:"LOGIN_LOCKOUT_MAX"`
+	AuthRateLimit      int           `mapstructure:"AUTH_RATE_LIMIT"`
+	AuthRateWindow     time.Duration `mapstructure:"AUTH_RATE_WINDOW"`
+
+	AppURL                 string        `mapstructure:"APP_URL"`
+	EmailVerifyExpiresIn   time.Duration `mapstructure:"EMAIL_VERIFY_EXPIRED_IN"`
+	PasswordResetExpiresIn time.Duration `mapstructure:"PASSWORD_RESET_EXPIRED_IN"`
+	PasswordResetURL       string        `mapstructure:"PASSWORD_RESET_URL"`
+	MailDriver             string        `mapstructure:"MAIL_DRIVER"`
+	MailFrom               string        `mapstructure:"MAIL_FROM"`
+	MailFileDir            string        `mapstructure:"MAIL_FILE_DIR"`
+	SMTPHost               string        `mapstructure:"SMTP_HOST"`
+	SMTPPort               string        `mapstructure:"SMTP_PORT"`
+	SMTPUsername           string        `mapstructure:"SMTP_USERNAME"`
+	SMTPPassword           string        `mapstructure:"SMTP_PASSWORD"`
+
+	LegacyItemStock          int           `mapstructure:"LEGACY_ITEM_STOCK"`
+	ReservationExpiresIn     time.Duration `mapstructure:"RESERVATION_EXPIRED_IN"`
+	ReservationSweepInterval time.Duration `mapstructure:"RESERVATION_SWEEP_INTERVAL"`
+
+	IdempotencyKeyExpiresIn time.Duration `mapstructure:"IDEMPOTENCY_KEY_EXPIRED_IN"`
+
+	PaymentProvider      string `mapstructure:"PAYMENT_PROVIDER"`
+	PaymentCurrency      string `mapstructure:"PAYMENT_CURRENCY"`
+	PaymentWebhookSecret string `mapstructure:"PAYMENT_WEBHOOK_SECRET"`
+	PaymentMockRoutes    bool   `mapstructure:"PAYMENT_MOCK_ROUTES"`
+
+	ShippingDefaultRate float64 `mapstructure:"SHIPPING_DEFAULT_RATE"`
 }
 
 func LoadConfig(path string) (config Config, err error) {
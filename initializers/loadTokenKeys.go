@@ -0,0 +1,38 @@
+package initializers
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gmkanat/Go-Shop/utils"
+)
+
+var TokenKeys *utils.KeySet
+
+func LoadTokenKeys(config *Config) {
+	// TOKEN_SECRET also signs email links and 2FA challenges, so it is
+	// needed whatever algorithm access tokens use.
+	if config.TokenSecret == "" {
+		log.Fatal("Failed to load token signing keys: TOKEN_SECRET is required")
+	}
+
+	var err error
+	switch config.TokenAlgorithm {
+	case "", "HS256":
+		TokenKeys = utils.NewHMACKeySet(config.TokenSecret)
+	default:
+		TokenKeys, err = utils.LoadKeySet(config.TokenAlgorithm, config.TokenPrivateKeyPath,
+			config.TokenKeyID, config.TokenVerifyKeys)
+		if err == nil {
+			TokenKeys.UsePurposeSecret(config.TokenSecret)
+			// Access tokens issued with HS256 before the switch keep working
+			// until they expire instead of logging everyone out.
+			TokenKeys.AcceptRetiredHMAC(config.TokenSecret, time.Now().Add(config.AccessTokenExpiresIn))
+		}
+	}
+	if err != nil {
+		log.Fatal("Failed to load token signing keys: ", err)
+	}
+	fmt.Println("🔑 Signing tokens with", TokenKeys.Algorithm())
+}
@@ -0,0 +1,35 @@
+package mailer
+
+import (
+	"fmt"
+
+	"github.com/gmkanat/Go-Shop/initializers"
+)
+
+// Message is a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers transactional emails such as verification links.
+type Mailer interface {
+	Send(message Message) error
+}
+
+// NewFromConfig builds the mailer selected by MAIL_DRIVER: "smtp", "file"
+// or "memory".
+func NewFromConfig(config *initializers.Config) (Mailer, error) {
+	switch config.MailDriver {
+	case "smtp":
+		return NewSMTPMailer(config.SMTPHost, config.SMTPPort,
+			config.SMTPUsername, config.SMTPPassword, config.MailFrom), nil
+	case "", "file":
+		return NewFileMailer(config.MailFileDir, config.MailFrom), nil
+	case "memory":
+		return NewMemoryMailer(), nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", config.MailDriver)
+	}
+}
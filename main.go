@@ -4,7 +4,11 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/gmkanat/Go-Shop/controllers"
 	"github.com/gmkanat/Go-Shop/initializers"
+	"github.com/gmkanat/Go-Shop/mailer"
 	"github.com/gmkanat/Go-Shop/middleware"
+	"github.com/gmkanat/Go-Shop/models"
+	"github.com/gmkanat/Go-Shop/payments"
+	"github.com/gmkanat/Go-Shop/ratelimit"
 	"github.com/gmkanat/Go-Shop/routes"
 	"log"
 	"net/http"
@@ -20,6 +24,21 @@ var (
 
 	ItemController      controllers.ItemController
 	ItemRouteController routes.ItemRouteController
+
+	CartController      controllers.CartController
+	CartRouteController routes.CartRouteController
+
+	SellerController      controllers.SellerController
+	SellerRouteController routes.SellerRouteController
+
+	PaymentController      controllers.PaymentController
+	PaymentRouteController routes.PaymentRouteController
+
+	ReturnController      controllers.ReturnController
+	ReturnRouteController routes.ReturnRouteController
+
+	AdminController      controllers.AdminController
+	AdminRouteController routes.AdminRouteController
 )
 
 func init() {
@@ -29,16 +48,42 @@ func init() {
 	}
 
 	initializers.ConnectDB(&config)
+	initializers.LoadTokenKeys(&config)
 
-	AuthController = controllers.NewAuthController(initializers.DB)
+	mail, err := mailer.NewFromConfig(&config)
+	if err != nil {
+		log.Fatal("? Could not configure mailer", err)
+	}
+
+	provider, err := payments.NewFromConfig(&config)
+	if err != nil {
+		log.Fatal("? Could not configure payment provider", err)
+	}
+
+	AuthController = controllers.NewAuthController(initializers.DB, mail, ratelimit.NewMemoryStore())
 	AuthRouteController = routes.NewAuthRouteController(AuthController)
 
-	UserController = controllers.NewUserController(initializers.DB)
+	UserController = controllers.NewUserController(initializers.DB, mail, provider)
 	UserRouteController = routes.NewRouteUserController(UserController)
 
-	ItemController = controllers.NewItemController(initializers.DB)
+	ItemController = controllers.NewItemController(initializers.DB, provider)
 	ItemRouteController = routes.NewRouteItemController(ItemController)
 
+	CartController = controllers.NewCartController(initializers.DB)
+	CartRouteController = routes.NewRouteCartController(CartController)
+
+	SellerController = controllers.NewSellerController(initializers.DB, provider)
+	SellerRouteController = routes.NewRouteSellerController(SellerController)
+
+	PaymentController = controllers.NewPaymentController(initializers.DB, provider)
+	PaymentRouteController = routes.NewRoutePaymentController(PaymentController)
+
+	ReturnController = controllers.NewReturnController(initializers.DB, provider)
+	ReturnRouteController = routes.NewRouteReturnController(ReturnController)
+
+	AdminController = controllers.NewAdminController(initializers.DB)
+	AdminRouteController = routes.NewRouteAdminController(AdminController)
+
 	server = gin.Default()
 }
 
@@ -48,15 +93,26 @@ func main() {
 		log.Fatal("? Could not load environment variables", err)
 	}
 
+	server.GET("/.well-known/jwks.json", AuthController.JWKS)
+
 	router := server.Group("/api")
 	router.GET("/health-checker", func(ctx *gin.Context) {
 		message := "Welcome to Golang with Gorm and Postgres"
 		ctx.JSON(http.StatusOK, gin.H{"status": "success", "message": message})
 	})
-	router.POST("orders/:id/status", middleware.DeserializeUser(), middleware.CheckUserRole(ItemController.DB), ItemController.OrderStatus)
-	router.POST("orders/:id/cancel", middleware.DeserializeUser(), middleware.CheckUserOrder(UserController.DB), UserController.CancelOrder)
+	router.POST("orders/:id/status", middleware.DeserializeUser(models.ScopeOrdersWrite), middleware.RequirePermission(ItemController.DB, models.PermOrdersUpdateStatus),
+		middleware.CheckOrderSeller(ItemController.DB), middleware.Idempotency(ItemController.DB), ItemController.OrderStatus)
+	router.POST("orders/:id/cancel", middleware.DeserializeUser(), middleware.CheckUserOrder(UserController.DB), middleware.Idempotency(UserController.DB), UserController.CancelOrder)
+	router.GET("orders/:id", middleware.DeserializeUser(models.ScopeOrdersRead), middleware.CheckUserOrder(UserController.DB), UserController.GetOrder)
+	router.GET("orders/:id/timeline", middleware.DeserializeUser(models.ScopeOrdersRead), middleware.CheckOrderAccess(UserController.DB), UserController.OrderTimeline)
 	AuthRouteController.AuthRoute(router)
 	UserRouteController.UserRoute(router)
 	ItemRouteController.ItemRoute(router)
+	CartRouteController.CartRoute(router)
+	SellerRouteController.SellerRoute(router)
+	PaymentRouteController.PaymentRoute(router)
+	ReturnRouteController.ReturnRoute(router)
+	AdminRouteController.AdminRoute(router)
+	controllers.StartReservationSweeper(initializers.DB, config.ReservationSweepInterval)
 	log.Fatal(server.Run(":" + config.ServerPort))
 }
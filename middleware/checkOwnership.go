@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gmkanat/Go-Shop/models"
+	"gorm.io/gorm"
+	"net/http"
+)
+
+// CheckItemOwner loads the item from the :id param and only lets its seller,
+// or a user with items:manage_any, through. It must run after
+// RequirePermission so that the role's permissions are loaded.
+func CheckItemOwner(DB *gorm.DB) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		currentUser := ctx.MustGet("currentUser").(models.User)
+		itemID := ctx.Param("id")
+		var item models.Item
+		DB.First(&item, itemID)
+		if item.ID == 0 {
+			ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"status": "fail", "message": "item not found",
+			})
+			return
+		}
+		if item.SellerID != currentUser.ID && !currentUser.Role.HasPermission(models.PermItemsManageAny) {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"status": "fail", "message": "You have not access",
+			})
+			return
+		}
+		ctx.Set("currentItem", item)
+		ctx.Next()
+	}
+}
+
+// CheckOrderSeller loads the order from the :id param and only lets a seller
+// with at least one line in it, or a user with orders:manage_any, through.
+// It must run after RequirePermission.
+func CheckOrderSeller(DB *gorm.DB) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		currentUser := ctx.MustGet("currentUser").(models.User)
+		orderID := ctx.Param("id")
+		var order models.Order
+		DB.First(&order, orderID)
+		if order.ID == 0 {
+			ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"status": "fail", "message": "order not found",
+			})
+			return
+		}
+		if !SellsInOrder(DB, order.ID, currentUser.ID) && !currentUser.Role.HasPermission(models.PermOrdersManageAny) {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"status": "fail", "message": "You have not access",
+			})
+			return
+		}
+		ctx.Set("currentOrder", order)
+		ctx.Next()
+	}
+}
+
+// CheckOrderAccess lets the buyer, sellers with a line in the order and
+// users with orders:manage_any read an order.
+func CheckOrderAccess(DB *gorm.DB) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		currentUser := ctx.MustGet("currentUser").(models.User)
+		orderID := ctx.Param("id")
+		var order models.Order
+		DB.First(&order, orderID)
+		if order.ID == 0 {
+			ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"status": "fail", "message": "order not found",
+			})
+			return
+		}
+		if order.UserID != currentUser.ID {
+			DB.Preload("Permissions").First(&currentUser.Role, currentUser.RoleId)
+			if !SellsInOrder(DB, order.ID, currentUser.ID) && !currentUser.Role.HasPermission(models.PermOrdersManageAny) {
+				ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+					"status": "fail", "message": "You have not access",
+				})
+				return
+			}
+		}
+		ctx.Set("currentOrder", order)
+		ctx.Next()
+	}
+}
+
+// SellsInOrder reports whether sellerID sold at least one line of the order.
+func SellsInOrder(DB *gorm.DB, orderID uint, sellerID uint) bool {
+	var lines int64
+	DB.Model(&models.OrderLine{}).Where("order_id = ? AND seller_id = ?", orderID, sellerID).Count(&lines)
+	return lines > 0
+}
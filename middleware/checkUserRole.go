@@ -2,25 +2,53 @@ package middleware
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/gmkanat/Go-Shop/initializers"
 	"github.com/gmkanat/Go-Shop/models"
 	"gorm.io/gorm"
 	"net/http"
+	"strings"
 )
 
-func CheckUserRole(DB *gorm.DB) gin.HandlerFunc {
+// RequirePermission lets the request through only when the current user's
+// role grants every one of the given permissions.
+func RequirePermission(DB *gorm.DB, permissions ...string) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		currentUser := ctx.MustGet("currentUser").(models.User)
-		DB.First(&currentUser.Role, currentUser.RoleId)
-		if currentUser.Role.Name != "seller" {
-			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{
-				"status": "fail", "message": "You have not access",
-			})
+		DB.Preload("Permissions").First(&currentUser.Role, currentUser.RoleId)
+		for _, permission := range permissions {
+			if !currentUser.Role.HasPermission(permission) {
+				ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+					"status": "fail", "message": "You have not access",
+				})
+				return
+			}
+		}
+		if !checkTwoFactorPolicy(ctx, currentUser) {
 			return
 		}
+		ctx.Set("currentUser", currentUser)
 		ctx.Next()
 	}
 }
 
+// checkTwoFactorPolicy aborts when the user's role is listed in
+// TWO_FACTOR_REQUIRED_ROLES but the user has not enrolled in 2FA yet.
+func checkTwoFactorPolicy(ctx *gin.Context, user models.User) bool {
+	if user.TOTPEnabledAt != nil {
+		return true
+	}
+	config, _ := initializers.LoadConfig(".")
+	for _, role := range config.TwoFactorRequiredRoles {
+		if strings.EqualFold(strings.TrimSpace(role), user.Role.Name) {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"status": "fail", "message": "Two-factor authentication is required for your role, please enable it",
+			})
+			return false
+		}
+	}
+	return true
+}
+
 func CheckUserOrder(DB *gorm.DB) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		currentUser := ctx.MustGet("currentUser").(models.User)
@@ -28,7 +56,7 @@ func CheckUserOrder(DB *gorm.DB) gin.HandlerFunc {
 		var order models.Order
 		DB.First(&order, orderID)
 		if order.ID == 0 {
-			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{
 				"status": "fail", "message": "order not found",
 			})
 			return
@@ -37,6 +65,7 @@ func CheckUserOrder(DB *gorm.DB) gin.HandlerFunc {
 			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{
 				"status": "fail", "message": "You have not access",
 			})
+			return
 		}
 		ctx.Set("currentOrder", order)
 		ctx.Next()
@@ -7,12 +7,21 @@ import (
 	"github.com/gmkanat/Go-Shop/utils"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-func DeserializeUser() gin.HandlerFunc {
+// DeserializeUser authenticates the request with a Bearer token, the token
+// cookie or an X-API-Key header. API keys are refused unless the route lists
+// scopes, and the key must carry all of them.
+func DeserializeUser(scopes ...string) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
+		if apiKey := ctx.GetHeader("X-API-Key"); apiKey != "" {
+			deserializeAPIKey(ctx, apiKey, scopes)
+			return
+		}
+
 		var token string
 		cookie, err := ctx.Cookie("token")
 
@@ -32,8 +41,7 @@ func DeserializeUser() gin.HandlerFunc {
 			return
 		}
 
-		config, _ := initializers.LoadConfig(".")
-		sub, err := utils.ValidateToken(token, config.TokenSecret)
+		claims, err := utils.ValidateToken(token, initializers.TokenKeys)
 		if err != nil {
 			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
 				"status": "fail", "message": err.Error(),
@@ -41,8 +49,19 @@ func DeserializeUser() gin.HandlerFunc {
 			return
 		}
 
+		if claims.ID != "" {
+			var revoked int64
+			initializers.DB.Model(&models.RevokedToken{}).Where("jti = ?", claims.ID).Count(&revoked)
+			if revoked > 0 {
+				ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+					"status": "fail", "message": "token has been revoked",
+				})
+				return
+			}
+		}
+
 		var user models.User
-		result := initializers.DB.First(&user, "id = ?", fmt.Sprint(sub))
+		result := initializers.DB.First(&user, "id = ?", fmt.Sprint(claims.Subject))
 		if result.Error != nil {
 			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{
 				"status":  "fail",
@@ -51,7 +70,67 @@ func DeserializeUser() gin.HandlerFunc {
 			return
 		}
 
+		if claims.SessionID != "" {
+			// Throttled so that a busy client does not write on every request.
+			now := time.Now()
+			initializers.DB.Model(&models.Session{}).
+				Where("id = ? AND last_seen_at < ?", claims.SessionID, now.Add(-time.Minute)).
+				Updates(map[string]interface{}{
+					"last_seen_at": now,
+					"ip_address":   ctx.ClientIP(),
+					"user_agent":   ctx.Request.UserAgent(),
+				})
+		}
+
 		ctx.Set("currentUser", user)
+		ctx.Set("currentToken", claims)
 		ctx.Next()
 	}
 }
+
+func deserializeAPIKey(ctx *gin.Context, apiKey string, scopes []string) {
+	if len(scopes) == 0 {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"status": "fail", "message": "API keys are not accepted on this endpoint",
+		})
+		return
+	}
+
+	var key models.APIKey
+	result := initializers.DB.Preload("User").
+		First(&key, "key_hash = ? AND revoked_at IS NULL", utils.HashToken(apiKey))
+	if result.Error != nil {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"status": "fail", "message": "invalid API key",
+		})
+		return
+	}
+
+	granted := strings.Fields(key.Scopes)
+	for _, scope := range scopes {
+		if !containsString(granted, scope) {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"status": "fail", "message": "API key is missing the " + scope + " scope",
+			})
+			return
+		}
+	}
+
+	now := time.Now()
+	initializers.DB.Model(&models.APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", key.ID, now.Add(-time.Minute)).
+		Update("last_used_at", now)
+
+	ctx.Set("currentUser", key.User)
+	ctx.Set("currentAPIKey", key)
+	ctx.Next()
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,160 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gmkanat/Go-Shop/initializers"
+	"github.com/gmkanat/Go-Shop/models"
+	"github.com/gmkanat/Go-Shop/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const maxIdempotencyKeyLength = 255
+
+// lastIdempotencyPrune is the unix time expired keys were last deleted.
+var lastIdempotencyPrune int64
+
+type idempotencyWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *idempotencyWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *idempotencyWriter) WriteString(data string) (int, error) {
+	w.body.WriteString(data)
+	return w.ResponseWriter.WriteString(data)
+}
+
+// Idempotency makes a POST safe to retry when the client sends an
+// Idempotency-Key header. The first request with a key runs normally and its
+// response is stored; retries with the same key and body get that response
+// replayed, and reusing the key for a different request is rejected with 422.
+// Requests without the header are passed through untouched. It must run after
+// DeserializeUser since keys are scoped per user.
+func Idempotency(DB *gorm.DB) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		key := ctx.GetHeader("Idempotency-Key")
+		if key == "" {
+			ctx.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"status": "fail", "message": "Idempotency-Key is too long",
+			})
+			return
+		}
+		currentUser := ctx.MustGet("currentUser").(models.User)
+
+		body, err := io.ReadAll(ctx.Request.Body)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"status": "fail", "message": err.Error()})
+			return
+		}
+		ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+		fingerprint := utils.HashToken(ctx.Request.Method + " " + ctx.Request.URL.Path + "\n" + string(body))
+
+		config, _ := initializers.LoadConfig(".")
+		now := time.Now()
+		pruneIdempotencyKeys(DB, now)
+		DB.Where("user_id = ? AND key = ? AND expires_at <= ?", currentUser.ID, key, now).Delete(&models.IdempotencyKey{})
+
+		record := models.IdempotencyKey{
+			UserID:      currentUser.ID,
+			Key:         key,
+			Fingerprint: fingerprint,
+			ExpiresAt:   now.Add(config.IdempotencyKeyExpiresIn),
+			CreatedAt:   now,
+		}
+		// The existing key can expire or be deleted between the insert and the
+		// lookup, so retry the insert a couple of times before giving up.
+		var existing models.IdempotencyKey
+		for attempt := 0; ; attempt++ {
+			result := DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+			if result.Error != nil {
+				ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{"status": "error", "message": result.Error.Error()})
+				return
+			}
+			if result.RowsAffected > 0 {
+				break
+			}
+			err := DB.Where("user_id = ? AND key = ?", currentUser.ID, key).First(&existing).Error
+			if err == nil {
+				break
+			}
+			if !errors.Is(err, gorm.ErrRecordNotFound) || attempt == 2 {
+				ctx.AbortWithStatusJSON(http.StatusBadGateway, gin.H{"status": "error", "message": err.Error()})
+				return
+			}
+		}
+
+		if existing.ID != 0 {
+			switch {
+			case existing.Fingerprint != fingerprint:
+				ctx.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{
+					"status": "fail", "message": "Idempotency-Key was already used for a different request",
+				})
+			case existing.CompletedAt == nil:
+				ctx.AbortWithStatusJSON(http.StatusConflict, gin.H{
+					"status": "fail", "message": "A request with this Idempotency-Key is still being processed",
+				})
+			default:
+				ctx.Header("Idempotent-Replayed", "true")
+				ctx.Data(existing.StatusCode, existing.ContentType, existing.Response)
+				ctx.Abort()
+			}
+			return
+		}
+
+		// A panicking handler never reaches the code after Next; free the key
+		// so retries aren't stuck on "still being processed" until it expires.
+		completed := false
+		defer func() {
+			if !completed {
+				DB.Delete(&record)
+			}
+		}()
+
+		writer := &idempotencyWriter{ResponseWriter: ctx.Writer}
+		ctx.Writer = writer
+		ctx.Next()
+
+		// Server errors are worth retrying, so don't pin them to the key.
+		if writer.Status() >= http.StatusInternalServerError {
+			return
+		}
+		completedAt := time.Now()
+		if err := DB.Model(&record).Updates(map[string]interface{}{
+			"status_code":  writer.Status(),
+			"content_type": writer.Header().Get("Content-Type"),
+			"response":     writer.body.Bytes(),
+			"completed_at": completedAt,
+		}).Error; err != nil {
+			log.Println("could not store idempotent response:", err)
+			return
+		}
+		completed = true
+	}
+}
+
+// pruneIdempotencyKeys deletes expired keys at most once an hour.
+func pruneIdempotencyKeys(DB *gorm.DB, now time.Time) {
+	last := atomic.LoadInt64(&lastIdempotencyPrune)
+	if now.Unix()-last < int64(time.Hour/time.Second) ||
+		!atomic.CompareAndSwapInt64(&lastIdempotencyPrune, last, now.Unix()) {
+		return
+	}
+	DB.Where("expires_at <= ?", now).Delete(&models.IdempotencyKey{})
+}
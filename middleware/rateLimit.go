@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/gmkanat/Go-Shop/ratelimit"
+	"log"
+	"math"
+	"net/http"
+	"time"
+)
+
+// RateLimitKey picks the bucket a request is counted against.
+type RateLimitKey func(ctx *gin.Context) string
+
+// ClientIPKey counts requests per client IP and route.
+func ClientIPKey(ctx *gin.Context) string {
+	return "ip:" + ctx.ClientIP() + ":" + ctx.FullPath()
+}
+
+// RateLimit allows at most limit requests per window for each key and answers
+// 429 with a Retry-After header beyond that.
+func RateLimit(store ratelimit.Store, limit int, window time.Duration, key RateLimitKey) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		entry, err := store.Increment("ratelimit:"+key(ctx), window)
+		if err != nil {
+			// Fail open: an unavailable store should not take the API down.
+			log.Println("rate limit store error:", err)
+			ctx.Next()
+			return
+		}
+
+		remaining := limit - entry.Count
+		if remaining < 0 {
+			remaining = 0
+		}
+		ctx.Header("X-RateLimit-Limit", fmt.Sprint(limit))
+		ctx.Header("X-RateLimit-Remaining", fmt.Sprint(remaining))
+
+		if entry.Count > limit {
+			retryAfter := time.Until(entry.ExpiresAt)
+			ctx.Header("Retry-After", fmt.Sprint(int(math.Ceil(retryAfter.Seconds()))))
+			ctx.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"status": "fail", "message": "Too many requests, please try again later",
+			})
+			return
+		}
+		ctx.Next()
+	}
+}
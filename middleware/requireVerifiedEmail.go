@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gmkanat/Go-Shop/models"
+	"net/http"
+)
+
+func RequireVerifiedEmail() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		currentUser := ctx.MustGet("currentUser").(models.User)
+		if currentUser.EmailVerifiedAt == nil {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"status": "fail", "message": "Please verify your email address first",
+			})
+			return
+		}
+		ctx.Next()
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"github.com/gmkanat/Go-Shop/initializers"
 	"github.com/gmkanat/Go-Shop/models"
+	"gorm.io/gorm"
 	"log"
+	"strings"
 )
 
 func init() {
@@ -26,7 +28,110 @@ func main() {
 	//if initializers.DB.Migrator().HasTable(&models.ItemRating{}) {
 	//	initializers.DB.Migrator().DropTable(&models.User{})
 	//}
-	initializers.DB.AutoMigrate(&models.User{}, models.UserRole{}, &models.Item{},
-		&models.ItemRating{}, &models.ItemComment{}, models.Order{})
+	config, _ := initializers.LoadConfig(".")
+
+	// Accounts from before email verification are trusted as verified so
+	// they aren't locked out of purchasing.
+	backfillEmailVerified := initializers.DB.Migrator().HasTable(&models.User{}) &&
+		!initializers.DB.Migrator().HasColumn(&models.User{}, "email_verified_at")
+	// Items from before stock tracking could always be bought; keep them
+	// on sale instead of leaving them at zero stock.
+	backfillStock := initializers.DB.Migrator().HasTable(&models.Item{}) &&
+		!initializers.DB.Migrator().HasColumn(&models.Item{}, "stock")
+	// Orders from before shipping costs only have a total, which was all
+	// item price.
+	backfillSubtotal := !initializers.DB.Migrator().HasColumn(&models.Order{}, "subtotal")
+	initializers.DB.AutoMigrate(&models.User{}, models.UserRole{}, &models.Permission{}, &models.Item{},
+		&models.ItemRating{}, &models.ItemComment{}, models.Order{},
+		&models.RefreshToken{}, &models.RevokedToken{}, &models.Session{},
+		&models.PasswordResetToken{}, &models.RecoveryCode{}, &models.SellerApplication{},
+		&models.APIKey{}, &models.EmailChangeRequest{},
+		&models.OrderStatusHistory{}, &models.OrderLine{}, &models.Cart{}, &models.CartItem{},
+		&models.StockReservation{}, &models.IdempotencyKey{},
+		&models.Payment{}, &models.PaymentEvent{},
+		&models.ReturnRequest{}, &models.ReturnLine{}, &models.ReturnPhoto{}, &models.Refund{},
+		&models.Address{}, &models.ShippingMethod{}, &models.OrderShipment{})
+	if backfillEmailVerified {
+		initializers.DB.Exec("UPDATE users SET email_verified_at = created_at WHERE email_verified_at IS NULL")
+	}
+	if backfillStock {
+		initializers.DB.Exec("UPDATE items SET stock = ?", config.LegacyItemStock)
+		fmt.Println("? Set stock of existing items to", config.LegacyItemStock)
+	}
+
+	// Orders used to point at a single item. Move that onto an order line,
+	// priced at the item's current price, and drop the old column. It all
+	// happens in one transaction so a failure never drops item_id before
+	// every order has its line.
+	if initializers.DB.Migrator().HasColumn(&models.Order{}, "item_id") {
+		err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(`INSERT INTO order_lines (order_id, item_id, seller_id, item_name, unit_price, quantity, subtotal)
+			SELECT orders.id, orders.item_id, items.seller_id, COALESCE(items.name, ''), COALESCE(items.price, 0), 1, COALESCE(items.price, 0)
+			FROM orders LEFT JOIN items ON orders.item_id = items.id
+			WHERE NOT EXISTS (SELECT 1 FROM order_lines WHERE order_lines.order_id = orders.id)`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`UPDATE orders SET total = (SELECT COALESCE(SUM(subtotal), 0) FROM order_lines WHERE order_lines.order_id = orders.id)`).Error; err != nil {
+				return err
+			}
+			return tx.Migrator().DropColumn(&models.Order{}, "item_id")
+		})
+		if err != nil {
+			log.Fatal("? Could not move order items onto order lines: ", err)
+		}
+		fmt.Println("? Moved order items onto order lines")
+	}
+	// Runs after the order lines move above, which recomputes total.
+	if backfillSubtotal {
+		initializers.DB.Exec("UPDATE orders SET subtotal = total")
+	}
+
+	created := map[string]bool{}
+	for _, permission := range models.DefaultPermissions {
+		var existing int64
+		initializers.DB.Model(&models.Permission{}).Where("name = ?", permission.Name).Count(&existing)
+		if existing == 0 {
+			initializers.DB.Create(&models.Permission{Name: permission.Name, Description: permission.Description})
+			created[permission.Name] = true
+		}
+	}
+	var allPermissions []models.Permission
+	initializers.DB.Find(&allPermissions)
+
+	for _, name := range []string{models.RoleBuyer, models.RoleSeller, models.RoleAdmin} {
+		var role models.UserRole
+		initializers.DB.Preload("Permissions").Where(models.UserRole{Name: name}).FirstOrCreate(&role)
+
+		if name == models.RoleAdmin {
+			initializers.DB.Model(&role).Association("Permissions").Replace(allPermissions)
+			continue
+		}
+		// Don't undo customisations: roles that already have permissions only
+		// get the defaults that were introduced by this migration.
+		var names []string
+		for _, permission := range models.DefaultRolePermissions[name] {
+			if len(role.Permissions) == 0 || created[permission] {
+				names = append(names, permission)
+			}
+		}
+		if len(names) > 0 {
+			var defaults []models.Permission
+			initializers.DB.Where("name IN ?", names).Find(&defaults)
+			initializers.DB.Model(&role).Association("Permissions").Append(defaults)
+		}
+	}
+	fmt.Println("? Roles and permissions seeded")
+
+	if config.AdminEmail != "" {
+		var adminRole models.UserRole
+		initializers.DB.First(&adminRole, "name = ?", models.RoleAdmin)
+		result := initializers.DB.Model(&models.User{}).
+			Where("email = ?", strings.ToLower(config.AdminEmail)).
+			Update("role_id", adminRole.ID)
+		if result.RowsAffected > 0 {
+			fmt.Println("? Granted admin role to", config.AdminEmail)
+		}
+	}
+
 	fmt.Println("? Migration complete")
 }
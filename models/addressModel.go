@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// Address is an entry in a buyer's address book. At most one address per
+// user is the default, which is used when an order doesn't name one.
+type Address struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	UserID        uint      `gorm:"not null;index" json:"user_id"`
+	Label         string    `gorm:"type:varchar(64)" json:"label"`
+	RecipientName string    `gorm:"not null" json:"recipient_name"`
+	Line1         string    `gorm:"not null" json:"line1"`
+	Line2         string    `json:"line2"`
+	City          string    `gorm:"not null" json:"city"`
+	Region        string    `json:"region"`
+	PostalCode    string    `gorm:"type:varchar(16);not null" json:"postal_code"`
+	Country       string    `gorm:"type:varchar(2);not null" json:"country"`
+	Phone         string    `gorm:"type:varchar(32)" json:"phone"`
+	IsDefault     bool      `gorm:"not null;default:false" json:"is_default"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// OrderAddress is the shipping address copied onto an order when it is
+// placed, so editing the address book later doesn't reroute old orders.
+type OrderAddress struct {
+	RecipientName string `json:"recipient_name"`
+	Line1         string `json:"line1"`
+	Line2         string `json:"line2"`
+	City          string `json:"city"`
+	Region        string `json:"region"`
+	PostalCode    string `gorm:"type:varchar(16)" json:"postal_code"`
+	Country       string `gorm:"type:varchar(2)" json:"country"`
+	Phone         string `gorm:"type:varchar(32)" json:"phone"`
+}
+
+func (a Address) Snapshot() OrderAddress {
+	return OrderAddress{
+		RecipientName: a.RecipientName,
+		Line1:         a.Line1,
+		Line2:         a.Line2,
+		City:          a.City,
+		Region:        a.Region,
+		PostalCode:    a.PostalCode,
+		Country:       a.Country,
+		Phone:         a.Phone,
+	}
+}
+
+type AddressInput struct {
+	Label         string `json:"label" binding:"max=64"`
+	RecipientName string `json:"recipient_name" binding:"required,max=255"`
+	Line1         string `json:"line1" binding:"required,max=255"`
+	Line2         string `json:"line2" binding:"max=255"`
+	City          string `json:"city" binding:"required,max=255"`
+	Region        string `json:"region" binding:"max=255"`
+	PostalCode    string `json:"postal_code" binding:"max=16"`
+	Country       string `json:"country" binding:"required"`
+	Phone         string `json:"phone" binding:"max=32"`
+	IsDefault     bool   `json:"is_default"`
+}
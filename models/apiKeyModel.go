@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// API key scopes. A key can only be used on routes that ask for one of its
+// scopes, and the owner's role must still grant the route's permission.
+const (
+	ScopeItemsRead   = "items:read"
+	ScopeItemsWrite  = "items:write"
+	ScopeOrdersRead  = "orders:read"
+	ScopeOrdersWrite = "orders:write"
+)
+
+var APIKeyScopes = []string{ScopeItemsRead, ScopeItemsWrite, ScopeOrdersRead, ScopeOrdersWrite}
+
+// APIKey is a long-lived credential for seller integrations. Only the hash
+// of the key is stored; Prefix is kept so users can tell keys apart.
+type APIKey struct {
+	ID         uint   `gorm:"primaryKey"`
+	UserID     uint   `gorm:"not null;index"`
+	Name       string `gorm:"type:varchar(255);not null"`
+	Prefix     string `gorm:"type:varchar(16);not null"`
+	KeyHash    string `gorm:"type:varchar(64);uniqueIndex;not null"`
+	Scopes     string `gorm:"not null"`
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+	CreatedAt  time.Time `gorm:"not null"`
+	User       User      `gorm:"foreignKey:UserID"`
+}
+
+type APIKeyInput struct {
+	Name   string   `json:"name" binding:"required"`
+	Scopes []string `json:"scopes" binding:"required,min=1"`
+}
+
+type APIKeyResponse struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
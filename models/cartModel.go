@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// Cart is a buyer's persistent shopping cart. Every user has at most one.
+type Cart struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"not null;uniqueIndex" json:"user_id"`
+	Items     []CartItem `gorm:"foreignKey:CartID" json:"items"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+type CartItem struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CartID    uint      `gorm:"not null;uniqueIndex:idx_cart_item" json:"cart_id"`
+	ItemID    uint      `gorm:"not null;uniqueIndex:idx_cart_item" json:"item_id"`
+	Quantity  int       `gorm:"not null" json:"quantity"`
+	Item      Item      `gorm:"foreignKey:ItemID" json:"item"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type CartItemInput struct {
+	ItemID   uint `json:"item_id" binding:"required"`
+	Quantity int  `json:"quantity" binding:"required,min=1"`
+}
+
+type CartQuantityInput struct {
+	Quantity int `json:"quantity" binding:"required,min=1"`
+}
+
+// CheckoutInput is the optional body of a checkout. Without AddressID the
+// buyer's default address is used.
+type CheckoutInput struct {
+	Comment   string `json:"comment"`
+	AddressID uint   `json:"address_id"`
+	// ShippingMethodIDs picks a method per seller; sellers without one in
+	// the list ship with their cheapest method that delivers.
+	ShippingMethodIDs []uint `json:"shipping_method_ids"`
+}
+
+type CartLineResponse struct {
+	ItemID    uint    `json:"item_id"`
+	Name      string  `json:"name"`
+	UnitPrice float64 `json:"unit_price"`
+	Quantity  int     `json:"quantity"`
+	Subtotal  float64 `json:"subtotal"`
+}
+
+type CartResponse struct {
+	Items     []CartLineResponse `json:"items"`
+	ItemCount int                `json:"item_count"`
+	Subtotal  float64            `json:"subtotal"`
+}
@@ -0,0 +1,67 @@
+package models
+
+import "time"
+
+// UserExport is the machine-readable copy of a user's personal data returned
+// by GET /users/me/export.
+type UserExport struct {
+	ExportedAt         time.Time           `json:"exported_at"`
+	Profile            UserExportProfile   `json:"profile"`
+	Orders             []UserExportOrder   `json:"orders"`
+	Ratings            []UserExportRating  `json:"ratings"`
+	Comments           []UserExportComment `json:"comments"`
+	Items              []UserExportItem    `json:"items"`
+	Sessions           []SessionResponse   `json:"sessions"`
+	APIKeys            []APIKeyResponse    `json:"api_keys"`
+	SellerApplications []SellerApplication `json:"seller_applications"`
+	Addresses          []Address           `json:"addresses"`
+}
+
+type UserExportProfile struct {
+	ID              uint       `json:"id"`
+	Name            string     `json:"name"`
+	Email           string     `json:"email"`
+	Role            string     `json:"role"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	TwoFactor       bool       `json:"two_factor_enabled"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+type UserExportOrder struct {
+	ID           uint                  `json:"id"`
+	Status       string                `json:"status"`
+	Comment      string                `json:"comment"`
+	ShippingCost float64               `json:"shipping_cost"`
+	Total        float64               `json:"total"`
+	ShipTo       OrderAddress          `json:"shipping_address"`
+	CreatedAt    time.Time             `json:"created_at"`
+	Lines        []UserExportOrderLine `json:"lines"`
+}
+
+type UserExportOrderLine struct {
+	ItemID    uint    `json:"item_id"`
+	ItemName  string  `json:"item_name"`
+	UnitPrice float64 `json:"unit_price"`
+	Quantity  int     `json:"quantity"`
+}
+
+type UserExportRating struct {
+	ID       uint    `json:"id"`
+	ItemID   uint    `json:"item_id"`
+	ItemName string  `json:"item_name"`
+	Rating   float64 `json:"rating"`
+}
+
+type UserExportComment struct {
+	ID       uint   `json:"id"`
+	ItemID   uint   `json:"item_id"`
+	ItemName string `json:"item_name"`
+	Comment  string `json:"comment"`
+}
+
+type UserExportItem struct {
+	ID    uint    `json:"id"`
+	Name  string  `json:"name"`
+	Price float64 `json:"price"`
+}
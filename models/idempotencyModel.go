@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// IdempotencyKey remembers the response to a request sent with an
+// Idempotency-Key header so a retry gets the same answer instead of running
+// the handler again. Keys are scoped to the user that sent them.
+type IdempotencyKey struct {
+	ID          uint   `gorm:"primaryKey"`
+	UserID      uint   `gorm:"not null;uniqueIndex:idx_idempotency_user_key"`
+	Key         string `gorm:"type:varchar(255);not null;uniqueIndex:idx_idempotency_user_key"`
+	Fingerprint string `gorm:"type:varchar(64);not null"`
+	StatusCode  int    `gorm:"not null;default:0"`
+	ContentType string `gorm:"type:varchar(255)"`
+	Response    []byte `gorm:"type:bytea"`
+	CompletedAt *time.Time
+	ExpiresAt   time.Time `gorm:"not null;index"`
+	CreatedAt   time.Time `gorm:"not null"`
+}
@@ -1,19 +1,39 @@
 package models
 
+import (
+	"errors"
+	"time"
+)
+
 type Item struct {
 	ID       uint    `gorm:"primaryKey" json:"id"`
 	Name     string  `gorm:"not null" json:"name"`
 	Price    float64 `gorm:"not null" json:"price"`
 	SellerID uint    `gorm:"default:null" json:"seller_id"`
 	Seller   User    `json:"seller" gorm:"foreignKey:SellerID"`
+	// Stock and Reserved are only ever changed with conditional updates (see
+	// controllers/orderLifecycle.go), never by saving a loaded Item. Units
+	// held by pending orders are counted in Reserved until the order is paid,
+	// so Stock - Reserved is what can still be sold.
+	Stock             int `gorm:"not null;default:0" json:"stock"`
+	Reserved          int `gorm:"not null;default:0" json:"reserved"`
+	LowStockThreshold int `gorm:"not null;default:0" json:"low_stock_threshold"`
+	// Packed weight and dimensions of one unit, used to price shipping.
+	WeightGrams int     `gorm:"not null;default:0" json:"weight_grams"`
+	LengthCm    float64 `gorm:"not null;default:0" json:"length_cm"`
+	WidthCm     float64 `gorm:"not null;default:0" json:"width_cm"`
+	HeightCm    float64 `gorm:"not null;default:0" json:"height_cm"`
 }
 
+var ErrOutOfStock = errors.New("item is out of stock")
+
 type ItemList struct {
 	ID         uint    `json:"id"`
 	Name       string  `json:"name"`
 	Price      float64 `json:"price"`
 	SellerName string  `json:"seller_name"`
 	AvgRating  float64 `json:"avg_rating"`
+	Stock      int     `json:"stock"`
 }
 type ItemDetail struct {
 	ItemList
@@ -25,8 +45,23 @@ type ItemDetailComment struct {
 	Email   string `json:"email"`
 }
 type ItemChange struct {
-	Name  string  `gorm:"not null" json:"name"`
-	Price float64 `gorm:"not null" json:"price"`
+	Name              string   `gorm:"not null" json:"name"`
+	Price             float64  `gorm:"not null" json:"price"`
+	Stock             *int     `json:"stock" binding:"omitempty,min=0"`
+	LowStockThreshold *int     `json:"low_stock_threshold" binding:"omitempty,min=0"`
+	WeightGrams       *int     `json:"weight_grams" binding:"omitempty,min=0"`
+	LengthCm          *float64 `json:"length_cm" binding:"omitempty,min=0"`
+	WidthCm           *float64 `json:"width_cm" binding:"omitempty,min=0"`
+	HeightCm          *float64 `json:"height_cm" binding:"omitempty,min=0"`
+}
+
+type LowStockItem struct {
+	ID                uint   `json:"id"`
+	Name              string `json:"name"`
+	Stock             int    `json:"stock"`
+	Reserved          int    `json:"reserved"`
+	Available         int    `json:"available"`
+	LowStockThreshold int    `json:"low_stock_threshold"`
 }
 
 type ItemRating struct {
@@ -68,17 +103,60 @@ type ItemCommentResponse struct {
 type Order struct {
 	ID      uint   `gorm:"primaryKey" json:"id"`
 	UserID  uint   `gorm:"not null" json:"user_id"`
-	ItemID  uint   `gorm:"not null" json:"item_id"`
 	Comment string `gorm:"not null" json:"comment"`
 	User    User   `gorm:"foreignKey:UserID" json:"user"`
-	Status  string `gorm:"default:'pending';noy null" json:"status"`
+	Status  string `gorm:"default:'pending';not null" json:"status"`
+	// Total is Subtotal, the price of the lines, plus ShippingCost.
+	Subtotal     float64         `gorm:"not null;default:0" json:"subtotal"`
+	ShippingCost float64         `gorm:"not null;default:0" json:"shipping_cost"`
+	Total        float64         `gorm:"not null;default:0" json:"total"`
+	Lines        []OrderLine     `gorm:"foreignKey:OrderID" json:"lines"`
+	Shipments    []OrderShipment `gorm:"foreignKey:OrderID" json:"shipments"`
+
+	ShippingAddress OrderAddress `gorm:"embedded;embeddedPrefix:shipping_" json:"shipping_address"`
+	CreatedAt       time.Time    `json:"created_at"`
+	UpdatedAt       time.Time    `json:"updated_at"`
 }
 
+// OrderLine is one item of an order. Name, seller and price are copied from
+// the item at checkout so later edits to the listing don't rewrite history.
+type OrderLine struct {
+	ID        uint    `gorm:"primaryKey" json:"id"`
+	OrderID   uint    `gorm:"not null;index" json:"order_id"`
+	ItemID    uint    `gorm:"not null;index" json:"item_id"`
+	SellerID  uint    `gorm:"index" json:"seller_id"`
+	ItemName  string  `gorm:"not null" json:"item_name"`
+	UnitPrice float64 `gorm:"not null" json:"unit_price"`
+	Quantity  int     `gorm:"not null" json:"quantity"`
+	Subtotal  float64 `gorm:"not null" json:"subtotal"`
+}
+
+// OrderClosedStatuses are the statuses after which an order needs no further
+// action from its buyer or seller.
+var OrderClosedStatuses = []string{OrderDelivered, OrderCanceled, OrderRefunded}
+
 type OrderResponse struct {
-	ID     uint   `json:"id"`
-	Status string `json:"status"`
+	ID           uint            `json:"id"`
+	Status       string          `json:"status"`
+	Subtotal     float64         `json:"subtotal,omitempty"`
+	ShippingCost float64         `json:"shipping_cost"`
+	Total        float64         `json:"total,omitempty"`
+	Lines        []OrderLine     `json:"lines,omitempty"`
+	Shipments    []OrderShipment `json:"shipments,omitempty"`
+}
+
+// PurchaseInput is the optional body of a purchase. Without AddressID the
+// buyer's default address is used.
+type PurchaseInput struct {
+	Quantity  int    `json:"quantity" binding:"omitempty,min=1"`
+	Comment   string `json:"comment"`
+	AddressID uint   `json:"address_id"`
+	// ShippingMethodID picks one of the seller's methods; the cheapest one
+	// that delivers is used otherwise.
+	ShippingMethodID uint `json:"shipping_method_id"`
 }
 
 type OrderChange struct {
-	Status string `json:"status"`
+	Status string `json:"status" binding:"required"`
+	Note   string `json:"note"`
 }
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// Order lifecycle:
+//
+//	pending -> paid -> shipped -> delivered
+//	pending/paid -> canceled
+//	paid/delivered -> refunded
+const (
+	OrderPending   = "pending"
+	OrderPaid      = "paid"
+	OrderShipped   = "shipped"
+	OrderDelivered = "delivered"
+	OrderCanceled  = "canceled"
+	OrderRefunded  = "refunded"
+)
+
+// Who moved an order from one status to another.
+const (
+	OrderActorBuyer  = "buyer"
+	OrderActorSeller = "seller"
+	OrderActorSystem = "system"
+)
+
+var ErrInvalidOrderTransition = errors.New("order status change is not allowed")
+
+// orderTransitions maps from -> to -> actors allowed to make that change.
+var orderTransitions = map[string]map[string][]string{
+	OrderPending: {
+		// Only a confirmed payment (the webhook) can mark an order paid.
+		OrderPaid:     {OrderActorSystem},
+		OrderCanceled: {OrderActorBuyer, OrderActorSeller, OrderActorSystem},
+	},
+	OrderPaid: {
+		OrderShipped:  {OrderActorSeller},
+		OrderCanceled: {OrderActorBuyer, OrderActorSeller},
+		OrderRefunded: {OrderActorSeller, OrderActorSystem},
+	},
+	OrderShipped: {
+		OrderDelivered: {OrderActorSeller, OrderActorSystem},
+	},
+	OrderDelivered: {
+		OrderRefunded: {OrderActorSeller, OrderActorSystem},
+	},
+}
+
+// CanTransitionOrder reports whether actor may move an order from one status
+// to another.
+func CanTransitionOrder(from string, to string, actor string) bool {
+	for _, allowed := range orderTransitions[from][to] {
+		if allowed == actor {
+			return true
+		}
+	}
+	return false
+}
+
+// OrderStatusHistory is one entry of an order's timeline.
+type OrderStatusHistory struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	OrderID     uint      `gorm:"not null;index" json:"order_id"`
+	FromStatus  string    `gorm:"type:varchar(16)" json:"from_status"`
+	ToStatus    string    `gorm:"type:varchar(16);not null" json:"to_status"`
+	Actor       string    `gorm:"type:varchar(16);not null" json:"actor"`
+	ChangedByID *uint     `json:"changed_by_id"`
+	Note        string    `gorm:"type:text" json:"note"`
+	CreatedAt   time.Time `gorm:"not null" json:"created_at"`
+}
+
+func (OrderStatusHistory) TableName() string {
+	return "order_status_history"
+}
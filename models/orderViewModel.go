@@ -0,0 +1,97 @@
+package models
+
+import "time"
+
+// Pagination describes one page of a list response.
+type Pagination struct {
+	Page       int   `json:"page"`
+	PerPage    int   `json:"per_page"`
+	Total      int64 `json:"total"`
+	TotalPages int   `json:"total_pages"`
+}
+
+type OrderSummary struct {
+	ID        uint      `json:"id"`
+	Status    string    `json:"status"`
+	Total     float64   `json:"total"`
+	ItemCount int       `json:"item_count"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type OrderSeller struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+// OrderDetailLine is an order line as it was bought, with the seller and
+// whether the item is still listed.
+type OrderDetailLine struct {
+	OrderLine
+	Seller       OrderSeller `json:"seller"`
+	StillListed  bool        `json:"still_listed"`
+	CurrentPrice *float64    `json:"current_price"`
+}
+
+type OrderDetail struct {
+	ID           uint                 `json:"id"`
+	Status       string               `json:"status"`
+	Comment      string               `json:"comment"`
+	Subtotal     float64              `json:"subtotal"`
+	ShippingCost float64              `json:"shipping_cost"`
+	Total        float64              `json:"total"`
+	ShipTo       OrderAddress         `json:"shipping_address"`
+	Shipments    []OrderShipment      `json:"shipments"`
+	Lines        []OrderDetailLine    `json:"lines"`
+	Timeline     []OrderStatusHistory `json:"timeline"`
+	Payments     []PaymentResponse    `json:"payments"`
+	Returns      []ReturnRequest      `json:"returns"`
+	Refunds      []Refund             `json:"refunds"`
+	CreatedAt    time.Time            `json:"created_at"`
+	UpdatedAt    time.Time            `json:"updated_at"`
+}
+
+// OrderStatuses lists every status an order can be in.
+var OrderStatuses = []string{OrderPending, OrderPaid, OrderShipped, OrderDelivered, OrderCanceled, OrderRefunded}
+
+// SellerOrderSummary is an order as one seller sees it: only their own lines
+// are counted.
+type SellerOrderSummary struct {
+	ID              uint      `json:"id"`
+	Status          string    `json:"status"`
+	BuyerName       string    `json:"buyer_name"`
+	SellerTotal     float64   `json:"seller_total"`
+	SellerItemCount int       `json:"seller_item_count"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+type BulkOrderStatusInput struct {
+	OrderIDs []uint `json:"order_ids" binding:"required,min=1,max=100"`
+	Status   string `json:"status" binding:"required"`
+	Note     string `json:"note"`
+}
+
+type BulkOrderStatusResult struct {
+	OrderID uint   `json:"order_id"`
+	Status  string `json:"status,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// PackingSlip has what a seller needs to pack their part of an order.
+type PackingSlip struct {
+	OrderID   uint              `json:"order_id"`
+	Status    string            `json:"status"`
+	BuyerName string            `json:"buyer_name"`
+	Comment   string            `json:"comment"`
+	ShipTo    OrderAddress      `json:"shipping_address"`
+	Shipping  []OrderShipment   `json:"shipping"`
+	Lines     []PackingSlipLine `json:"lines"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+type PackingSlipLine struct {
+	ItemID   uint   `json:"item_id"`
+	ItemName string `json:"item_name"`
+	Quantity int    `json:"quantity"`
+}
@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+const (
+	PaymentPending   = "pending"
+	PaymentSucceeded = "succeeded"
+	PaymentFailed    = "failed"
+	// PaymentCanceled is a payment that was authorized after its order had
+	// already been canceled, so it was never captured. Payments captured
+	// after that point are refunded instead.
+	PaymentCanceled = "canceled"
+	PaymentRefunded = "refunded"
+)
+
+// Payment is one attempt to pay for an order through a payment provider.
+type Payment struct {
+	ID       uint   `gorm:"primaryKey" json:"id"`
+	OrderID  uint   `gorm:"not null;index" json:"order_id"`
+	Provider string `gorm:"type:varchar(32);not null" json:"provider"`
+	IntentID string `gorm:"type:varchar(128);uniqueIndex;not null" json:"intent_id"`
+	// ClientSecret is kept so an open intent can be handed out again instead
+	// of starting a second one.
+	ClientSecret   string    `gorm:"type:varchar(255)" json:"-"`
+	Amount         float64   `gorm:"not null" json:"amount"`
+	Currency       string    `gorm:"type:varchar(3);not null" json:"currency"`
+	Status         string    `gorm:"type:varchar(32);not null;default:'pending'" json:"status"`
+	RefundedAmount float64   `gorm:"not null;default:0" json:"refunded_amount"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// PaymentEvent records processed webhook events so redelivered ones are
+// ignored.
+type PaymentEvent struct {
+	ID        uint      `gorm:"primaryKey"`
+	Provider  string    `gorm:"type:varchar(32);not null;uniqueIndex:idx_payment_event"`
+	EventID   string    `gorm:"type:varchar(128);not null;uniqueIndex:idx_payment_event"`
+	Type      string    `gorm:"type:varchar(64);not null"`
+	CreatedAt time.Time `gorm:"not null"`
+}
+
+type PaymentResponse struct {
+	ID           uint    `json:"id"`
+	OrderID      uint    `json:"order_id"`
+	Provider     string  `json:"provider"`
+	IntentID     string  `json:"intent_id"`
+	ClientSecret string  `json:"client_secret,omitempty"`
+	Amount       float64 `json:"amount"`
+	Currency     string  `json:"currency"`
+	Status       string  `json:"status"`
+}
@@ -0,0 +1,55 @@
+package models
+
+// Permission is a named capability that can be granted to roles, e.g.
+// "items:create".
+type Permission struct {
+	ID          uint   `gorm:"primaryKey" json:"id"`
+	Name        string `gorm:"type:varchar(64);uniqueIndex;not null" json:"name"`
+	Description string `json:"description"`
+}
+
+const (
+	PermItemsCreate              = "items:create"
+	PermItemsUpdate              = "items:update"
+	PermItemsDelete              = "items:delete"
+	PermItemsManageAny           = "items:manage_any"
+	PermOrdersUpdateStatus       = "orders:update_status"
+	PermOrdersManageAny          = "orders:manage_any"
+	PermCommentsModerate         = "comments:moderate"
+	PermSellerApplicationsReview = "seller_applications:review"
+	PermRolesManage              = "roles:manage"
+	PermAPIKeysManage            = "api_keys:manage"
+)
+
+// DefaultPermissions lists every built-in permission with its description.
+var DefaultPermissions = []Permission{
+	{Name: PermItemsCreate, Description: "Create listings"},
+	{Name: PermItemsUpdate, Description: "Update own listings"},
+	{Name: PermItemsDelete, Description: "Delete own listings"},
+	{Name: PermItemsManageAny, Description: "Update or delete any listing"},
+	{Name: PermOrdersUpdateStatus, Description: "Change the status of orders for own items"},
+	{Name: PermOrdersManageAny, Description: "Change the status of any order"},
+	{Name: PermCommentsModerate, Description: "Delete any comment"},
+	{Name: PermSellerApplicationsReview, Description: "Approve or reject seller applications"},
+	{Name: PermRolesManage, Description: "Create roles and grant them to users"},
+	{Name: PermAPIKeysManage, Description: "Create and revoke API keys for integrations"},
+}
+
+// DefaultRolePermissions is what the migrate command grants to built-in roles.
+// Roles that were already customised only receive permissions that did not
+// exist before. Admins always receive every permission.
+var DefaultRolePermissions = map[string][]string{
+	RoleBuyer: {},
+	RoleSeller: {
+		PermItemsCreate, PermItemsUpdate, PermItemsDelete, PermOrdersUpdateStatus, PermAPIKeysManage,
+	},
+}
+
+type RoleInput struct {
+	Name        string   `json:"name" binding:"required"`
+	Permissions []string `json:"permissions"`
+}
+
+type RolePermissionsInput struct {
+	Permissions []string `json:"permissions" binding:"required"`
+}
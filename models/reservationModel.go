@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// StockReservation holds Quantity units of an item for a pending order.
+// While it is active the units are counted in Item.Reserved; paying the order
+// commits it into a real stock decrement, and canceling the order or letting
+// it expire releases it.
+type StockReservation struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	OrderID     uint       `gorm:"not null;index" json:"order_id"`
+	ItemID      uint       `gorm:"not null;index" json:"item_id"`
+	Quantity    int        `gorm:"not null" json:"quantity"`
+	ExpiresAt   time.Time  `gorm:"not null;index" json:"expires_at"`
+	CommittedAt *time.Time `json:"committed_at"`
+	ReleasedAt  *time.Time `json:"released_at"`
+	CreatedAt   time.Time  `gorm:"not null" json:"created_at"`
+}
+
+func (r StockReservation) Active() bool {
+	return r.CommittedAt == nil && r.ReleasedAt == nil
+}
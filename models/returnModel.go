@@ -0,0 +1,72 @@
+package models
+
+import "time"
+
+const (
+	ReturnRequested = "requested"
+	ReturnApproved  = "approved"
+	ReturnRejected  = "rejected"
+)
+
+// ReturnRequest is a buyer asking to send back some or all of a delivered
+// order. Approving it restocks the returned units and refunds the buyer.
+type ReturnRequest struct {
+	ID           uint          `gorm:"primaryKey" json:"id"`
+	OrderID      uint          `gorm:"not null;index" json:"order_id"`
+	UserID       uint          `gorm:"not null;index" json:"user_id"`
+	Status       string        `gorm:"type:varchar(16);not null;default:'requested'" json:"status"`
+	Reason       string        `gorm:"not null" json:"reason"`
+	Lines        []ReturnLine  `gorm:"foreignKey:ReturnRequestID" json:"lines"`
+	Photos       []ReturnPhoto `gorm:"foreignKey:ReturnRequestID" json:"photos"`
+	RefundAmount float64       `gorm:"not null;default:0" json:"refund_amount"`
+	SellerNote   string        `json:"seller_note"`
+	ResolvedByID *uint         `json:"resolved_by_id"`
+	ResolvedAt   *time.Time    `json:"resolved_at"`
+	CreatedAt    time.Time     `json:"created_at"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+}
+
+type ReturnLine struct {
+	ID              uint `gorm:"primaryKey" json:"id"`
+	ReturnRequestID uint `gorm:"not null;index" json:"return_request_id"`
+	OrderLineID     uint `gorm:"not null;index" json:"order_line_id"`
+	ItemID          uint `gorm:"not null" json:"item_id"`
+	Quantity        int  `gorm:"not null" json:"quantity"`
+}
+
+type ReturnPhoto struct {
+	ID              uint   `gorm:"primaryKey" json:"id"`
+	ReturnRequestID uint   `gorm:"not null;index" json:"return_request_id"`
+	URL             string `gorm:"type:varchar(2048);not null" json:"url"`
+}
+
+// Refund is money sent back to the buyer on a payment.
+type Refund struct {
+	ID               uint      `gorm:"primaryKey" json:"id"`
+	PaymentID        uint      `gorm:"not null;index" json:"payment_id"`
+	ReturnRequestID  *uint     `gorm:"index" json:"return_request_id"`
+	ProviderRefundID string    `gorm:"type:varchar(128)" json:"provider_refund_id"`
+	Amount           float64   `gorm:"not null" json:"amount"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+type ReturnLineInput struct {
+	OrderLineID uint `json:"order_line_id" binding:"required"`
+	Quantity    int  `json:"quantity" binding:"required,min=1"`
+}
+
+// ReturnRequestInput opens a return. Without Lines the whole order is
+// returned.
+type ReturnRequestInput struct {
+	Reason string            `json:"reason" binding:"required"`
+	Photos []string          `json:"photos" binding:"max=10,dive,url"`
+	Lines  []ReturnLineInput `json:"lines" binding:"dive"`
+}
+
+// ReturnReviewInput approves or rejects a return. On approval RefundAmount
+// defaults to the value of the returned lines; a smaller amount is a partial
+// refund.
+type ReturnReviewInput struct {
+	Note         string   `json:"note"`
+	RefundAmount *float64 `json:"refund_amount" binding:"omitempty,min=0"`
+}
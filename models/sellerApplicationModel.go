@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+const (
+	SellerApplicationPending  = "pending"
+	SellerApplicationApproved = "approved"
+	SellerApplicationRejected = "rejected"
+)
+
+// SellerApplication is a buyer's request to be promoted to the seller role.
+type SellerApplication struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	UserID       uint       `gorm:"not null;index" json:"user_id"`
+	Message      string     `gorm:"type:text" json:"message"`
+	Status       string     `gorm:"type:varchar(16);default:'pending';not null;index" json:"status"`
+	ReviewNote   string     `gorm:"type:text" json:"review_note"`
+	ReviewedByID *uint      `json:"reviewed_by_id"`
+	ReviewedAt   *time.Time `json:"reviewed_at"`
+	CreatedAt    time.Time  `gorm:"not null" json:"created_at"`
+	User         User       `gorm:"foreignKey:UserID" json:"-"`
+}
+
+type SellerApplicationInput struct {
+	Message string `json:"message"`
+}
+
+type SellerApplicationReview struct {
+	Note string `json:"note"`
+}
+
+type SellerApplicationResponse struct {
+	ID         uint       `json:"id"`
+	UserID     uint       `json:"user_id"`
+	UserName   string     `json:"user_name"`
+	UserEmail  string     `json:"user_email"`
+	Message    string     `json:"message"`
+	Status     string     `json:"status"`
+	ReviewNote string     `json:"review_note"`
+	ReviewedAt *time.Time `json:"reviewed_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
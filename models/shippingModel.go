@@ -0,0 +1,96 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/gmkanat/Go-Shop/shipping"
+)
+
+// ShippingMethod is one way a seller ships their items, priced by the
+// provider from the shipping package that Type names.
+type ShippingMethod struct {
+	ID       uint    `gorm:"primaryKey" json:"id"`
+	SellerID uint    `gorm:"not null;index" json:"seller_id"`
+	Type     string  `gorm:"type:varchar(32);not null" json:"type"`
+	Label    string  `gorm:"not null" json:"label"`
+	FlatCost float64 `gorm:"not null;default:0" json:"flat_cost"`
+	FreeOver float64 `gorm:"not null;default:0" json:"free_over"`
+	// Tiers is the JSON encoded weight table of weight_tiered methods.
+	Tiers string `gorm:"type:text" json:"-"`
+	// Countries is a space-separated list of ISO codes; empty ships
+	// everywhere.
+	Countries string    `gorm:"type:text" json:"-"`
+	Active    bool      `gorm:"not null;default:true" json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (m ShippingMethod) TierList() []shipping.Tier {
+	tiers := []shipping.Tier{}
+	if m.Tiers != "" {
+		json.Unmarshal([]byte(m.Tiers), &tiers)
+	}
+	return tiers
+}
+
+func (m ShippingMethod) CountryList() []string {
+	return strings.Fields(m.Countries)
+}
+
+func (m ShippingMethod) ProviderConfig() shipping.Config {
+	return shipping.Config{
+		Type:      m.Type,
+		Label:     m.Label,
+		FlatCost:  m.FlatCost,
+		Tiers:     m.TierList(),
+		FreeOver:  m.FreeOver,
+		Countries: m.CountryList(),
+	}
+}
+
+type ShippingMethodInput struct {
+	Type      string          `json:"type" binding:"required,oneof=flat weight_tiered"`
+	Label     string          `json:"label" binding:"required,max=255"`
+	FlatCost  float64         `json:"flat_cost" binding:"min=0"`
+	Tiers     []shipping.Tier `json:"tiers"`
+	FreeOver  float64         `json:"free_over" binding:"min=0"`
+	Countries []string        `json:"countries"`
+	Active    *bool           `json:"active"`
+}
+
+type ShippingMethodResponse struct {
+	ID        uint            `json:"id"`
+	Type      string          `json:"type"`
+	Label     string          `json:"label"`
+	FlatCost  float64         `json:"flat_cost"`
+	Tiers     []shipping.Tier `json:"tiers"`
+	FreeOver  float64         `json:"free_over"`
+	Countries []string        `json:"countries"`
+	Active    bool            `json:"active"`
+}
+
+// OrderShipment is the shipping a buyer picked for one seller's part of an
+// order, with the cost it was quoted at.
+type OrderShipment struct {
+	ID               uint    `gorm:"primaryKey" json:"id"`
+	OrderID          uint    `gorm:"not null;index" json:"order_id"`
+	SellerID         uint    `gorm:"not null" json:"seller_id"`
+	ShippingMethodID *uint   `json:"shipping_method_id"`
+	Label            string  `gorm:"not null" json:"label"`
+	Cost             float64 `gorm:"not null" json:"cost"`
+}
+
+// ShippingOption is a quoted method for one seller's part of a cart.
+type ShippingOption struct {
+	ShippingMethodID *uint   `json:"shipping_method_id"`
+	Label            string  `json:"label"`
+	Cost             float64 `json:"cost"`
+}
+
+type SellerShippingOptions struct {
+	SellerID uint             `json:"seller_id"`
+	Subtotal float64          `json:"subtotal"`
+	Options  []ShippingOption `json:"options"`
+}
@@ -0,0 +1,68 @@
+package models
+
+import "time"
+
+// RefreshToken is a single-use refresh token. Tokens issued from the same
+// login share a FamilyID so the whole chain can be revoked on reuse.
+type RefreshToken struct {
+	ID        uint       `gorm:"primaryKey"`
+	UserID    uint       `gorm:"not null;index"`
+	FamilyID  string     `gorm:"type:varchar(64);not null;index"`
+	TokenHash string     `gorm:"type:varchar(64);uniqueIndex;not null"`
+	ExpiresAt time.Time  `gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt time.Time  `gorm:"not null"`
+	User      User       `gorm:"foreignKey:UserID"`
+
+	// The access token issued alongside this refresh token, so revoking the
+	// family also kills the access tokens that are still in flight.
+	AccessTokenID        string `gorm:"type:varchar(64);index"`
+	AccessTokenExpiresAt time.Time
+}
+
+// RevokedToken is a deny-list entry for an access token jti. Rows can be
+// pruned once ExpiresAt has passed since the token is no longer valid anyway.
+type RevokedToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	JTI       string    `gorm:"type:varchar(64);uniqueIndex;not null"`
+	UserID    uint      `gorm:"not null;index"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	CreatedAt time.Time `gorm:"not null"`
+}
+
+// PasswordResetToken is a single-use, hashed "forgot password" token.
+type PasswordResetToken struct {
+	ID        uint       `gorm:"primaryKey"`
+	UserID    uint       `gorm:"not null;index"`
+	TokenHash string     `gorm:"type:varchar(64);uniqueIndex;not null"`
+	ExpiresAt time.Time  `gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `gorm:"not null"`
+}
+
+type RefreshInput struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Session is one login on one device. Its ID is the refresh token family
+// and the "sid" claim of every access token issued for it.
+type Session struct {
+	ID         string     `gorm:"type:varchar(64);primaryKey" json:"id"`
+	UserID     uint       `gorm:"not null;index" json:"user_id"`
+	IPAddress  string     `gorm:"type:varchar(64)" json:"ip_address"`
+	UserAgent  string     `gorm:"type:varchar(512)" json:"user_agent"`
+	CreatedAt  time.Time  `gorm:"not null" json:"created_at"`
+	LastSeenAt time.Time  `gorm:"not null" json:"last_seen_at"`
+	ExpiresAt  time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+type SessionResponse struct {
+	ID         string    `json:"id"`
+	IPAddress  string    `json:"ip_address"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Current    bool      `json:"current"`
+}
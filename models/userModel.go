@@ -12,14 +12,21 @@ type User struct {
 	UpdatedAt time.Time `gorm:"not null"`
 	RoleId    uint      `gorm:"not null" json:"role_id"`
 	Role      UserRole  `gorm:"foreignKey:RoleId" json:"role"`
+
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+
+	TOTPSecret    string     `json:"-"`
+	TOTPEnabledAt *time.Time `json:"totp_enabled_at"`
+	TOTPLastStep  int64      `json:"-"`
+
+	AnonymizedAt *time.Time `json:"anonymized_at"`
 }
 
 type SignUpInput struct {
 	Name            string `json:"name" binding:"required"`
-	Email           string `json:"email" binding:"required"`
+	Email           string `json:"email" binding:"required,email"`
 	Password        string `json:"password" binding:"required,min=8"`
 	PasswordConfirm string `json:"password_confirm" binding:"required"`
-	RoleId          uint   `json:"role_id" binding:"required"`
 }
 
 type SignInInput struct {
@@ -28,14 +35,84 @@ type SignInInput struct {
 }
 
 type UserResponse struct {
-	ID        uint      `json:"id,omitempty"`
-	Name      string    `json:"name,omitempty"`
-	Email     string    `json:"email,omitempty"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID            uint      `json:"id,omitempty"`
+	Name          string    `json:"name,omitempty"`
+	Email         string    `json:"email,omitempty"`
+	EmailVerified bool      `json:"email_verified"`
+	TwoFactor     bool      `json:"two_factor_enabled"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+type ResendVerificationInput struct {
+	Email string `json:"email" binding:"required"`
+}
+
+type ForgotPasswordInput struct {
+	Email string `json:"email" binding:"required"`
+}
+
+type ResetPasswordInput struct {
+	Token           string `json:"token" binding:"required"`
+	Password        string `json:"password" binding:"required,min=8"`
+	PasswordConfirm string `json:"password_confirm" binding:"required"`
 }
 
 type UserRole struct {
-	ID   uint   `gorm:"primaryKey"`
-	Name string `gorm:"not null" json:"role"`
+	ID          uint         `gorm:"primaryKey"`
+	Name        string       `gorm:"uniqueIndex;not null" json:"role"`
+	Permissions []Permission `gorm:"many2many:role_permissions;" json:"permissions,omitempty"`
+}
+
+// HasPermission reports whether the role grants the named permission. The
+// role must have been loaded with Preload("Permissions").
+func (r UserRole) HasPermission(name string) bool {
+	for _, permission := range r.Permissions {
+		if permission.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Built-in roles seeded by the migrate command.
+const (
+	RoleBuyer  = "buyer"
+	RoleSeller = "seller"
+	RoleAdmin  = "admin"
+)
+
+type RoleChange struct {
+	Role string `json:"role" binding:"required"`
+}
+
+type UpdateProfileInput struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type ChangePasswordInput struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	Password        string `json:"password" binding:"required,min=8"`
+	PasswordConfirm string `json:"password_confirm" binding:"required"`
+}
+
+type ChangeEmailInput struct {
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+}
+
+// EmailChangeRequest holds a pending address change until the new address
+// is confirmed through the emailed link.
+type EmailChangeRequest struct {
+	ID        uint       `gorm:"primaryKey"`
+	UserID    uint       `gorm:"not null;index"`
+	NewEmail  string     `gorm:"not null"`
+	TokenHash string     `gorm:"type:varchar(64);uniqueIndex;not null"`
+	ExpiresAt time.Time  `gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `gorm:"not null"`
+}
+
+type DeleteAccountInput struct {
+	Password string `json:"password" binding:"required"`
 }
@@ -0,0 +1,191 @@
+package payments
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// webhookTolerance is how old a signed webhook may be before it's rejected
+// as a possible replay.
+const webhookTolerance = 5 * time.Minute
+
+// MockProvider is an in-memory gateway for development and tests. Nothing
+// leaves the process: intents live in a map, and Pay produces the signed
+// webhook a real provider would send once the buyer has paid.
+type MockProvider struct {
+	secret []byte
+
+	mu       sync.Mutex
+	intents  map[string]*Intent
+	refunded map[string]int64
+}
+
+func NewMockProvider(secret string) *MockProvider {
+	return &MockProvider{
+		secret:   []byte(secret),
+		intents:  map[string]*Intent{},
+		refunded: map[string]int64{},
+	}
+}
+
+func (p *MockProvider) Name() string {
+	return "mock"
+}
+
+func (p *MockProvider) CreateIntent(amount int64, currency string, reference string) (Intent, error) {
+	if amount <= 0 {
+		return Intent{}, fmt.Errorf("payment amount must be positive, got %d", amount)
+	}
+	id, err := randomID("pi_mock_")
+	if err != nil {
+		return Intent{}, err
+	}
+	secret, err := randomID(id + "_secret_")
+	if err != nil {
+		return Intent{}, err
+	}
+	intent := &Intent{ID: id, ClientSecret: secret, Amount: amount, Currency: currency, Status: IntentRequiresPayment}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.intents[id] = intent
+	return *intent, nil
+}
+
+func (p *MockProvider) Capture(intentID string) (Intent, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	intent, ok := p.intents[intentID]
+	if !ok {
+		return Intent{}, ErrUnknownIntent
+	}
+	switch intent.Status {
+	case IntentAuthorized:
+		intent.Status = IntentSucceeded
+	case IntentSucceeded:
+	default:
+		return Intent{}, fmt.Errorf("intent %s is %s and can't be captured", intentID, intent.Status)
+	}
+	return *intent, nil
+}
+
+func (p *MockProvider) Refund(intentID string, amount int64) (Refund, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	intent, ok := p.intents[intentID]
+	if !ok {
+		return Refund{}, ErrUnknownIntent
+	}
+	if intent.Status != IntentSucceeded {
+		return Refund{}, fmt.Errorf("intent %s is %s and can't be refunded", intentID, intent.Status)
+	}
+	if amount <= 0 || p.refunded[intentID]+amount > intent.Amount {
+		return Refund{}, ErrRefundTooLarge
+	}
+	id, err := randomID("re_mock_")
+	if err != nil {
+		return Refund{}, err
+	}
+	p.refunded[intentID] += amount
+	return Refund{ID: id, IntentID: intentID, Amount: amount}, nil
+}
+
+// Pay simulates the buyer completing payment: the intent becomes authorized
+// and the matching webhook payload and signature are returned.
+func (p *MockProvider) Pay(intentID string) ([]byte, string, error) {
+	p.mu.Lock()
+	intent, ok := p.intents[intentID]
+	if ok {
+		intent.Status = IntentAuthorized
+	}
+	p.mu.Unlock()
+	if !ok {
+		return nil, "", ErrUnknownIntent
+	}
+	return p.event(EventPaymentAuthorized, *intent)
+}
+
+// Decline simulates a failed payment and returns its webhook.
+func (p *MockProvider) Decline(intentID string) ([]byte, string, error) {
+	p.mu.Lock()
+	intent, ok := p.intents[intentID]
+	if ok {
+		intent.Status = IntentCanceled
+	}
+	p.mu.Unlock()
+	if !ok {
+		return nil, "", ErrUnknownIntent
+	}
+	return p.event(EventPaymentFailed, *intent)
+}
+
+func (p *MockProvider) event(eventType string, intent Intent) ([]byte, string, error) {
+	id, err := randomID("evt_mock_")
+	if err != nil {
+		return nil, "", err
+	}
+	payload, err := json.Marshal(Event{ID: id, Type: eventType, IntentID: intent.ID, Amount: intent.Amount})
+	if err != nil {
+		return nil, "", err
+	}
+	return payload, p.Sign(payload, time.Now()), nil
+}
+
+// Sign returns the signature header for payload: "t=<unix>,v1=<hex hmac>",
+// where the HMAC-SHA256 covers "<unix>.<payload>".
+func (p *MockProvider) Sign(payload []byte, at time.Time) string {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	return "t=" + timestamp + ",v1=" + p.mac(timestamp, payload)
+}
+
+func (p *MockProvider) VerifyWebhook(payload []byte, signature string) (Event, error) {
+	var timestamp, mac string
+	for _, part := range strings.Split(signature, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			mac = value
+		}
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || mac == "" {
+		return Event{}, ErrInvalidSignature
+	}
+	if age := time.Since(time.Unix(unix, 0)); age > webhookTolerance || age < -webhookTolerance {
+		return Event{}, ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(mac), []byte(p.mac(timestamp, payload))) {
+		return Event{}, ErrInvalidSignature
+	}
+
+	var event Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return Event{}, fmt.Errorf("malformed webhook payload: %w", err)
+	}
+	return event, nil
+}
+
+func (p *MockProvider) mac(timestamp string, payload []byte) string {
+	h := hmac.New(sha256.New, p.secret)
+	h.Write([]byte(timestamp + "."))
+	h.Write(payload)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func randomID(prefix string) (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return prefix + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,83 @@
+package payments
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gmkanat/Go-Shop/initializers"
+)
+
+// Intent statuses.
+const (
+	IntentRequiresPayment = "requires_payment"
+	IntentAuthorized      = "authorized"
+	IntentSucceeded       = "succeeded"
+	IntentCanceled        = "canceled"
+)
+
+// Webhook event types.
+const (
+	EventPaymentAuthorized = "payment.authorized"
+	EventPaymentSucceeded  = "payment.succeeded"
+	EventPaymentFailed     = "payment.failed"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrUnknownIntent    = errors.New("unknown payment intent")
+	ErrRefundTooLarge   = errors.New("refund exceeds the captured amount")
+)
+
+// Intent is a provider-side attempt to collect Amount, in minor units of
+// Currency, for one order.
+type Intent struct {
+	ID           string
+	ClientSecret string
+	Amount       int64
+	Currency     string
+	Status       string
+}
+
+// Refund is money sent back on a captured intent.
+type Refund struct {
+	ID       string
+	IntentID string
+	Amount   int64
+}
+
+// Event is a verified webhook notification.
+type Event struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	IntentID string `json:"intent_id"`
+	Amount   int64  `json:"amount"`
+}
+
+// Provider is a payment gateway. Buyers pay against an intent on the
+// provider's side and the provider reports the outcome through a signed
+// webhook; authorized intents must then be captured to collect the money.
+type Provider interface {
+	Name() string
+	CreateIntent(amount int64, currency string, reference string) (Intent, error)
+	Capture(intentID string) (Intent, error)
+	Refund(intentID string, amount int64) (Refund, error)
+	VerifyWebhook(payload []byte, signature string) (Event, error)
+}
+
+// NewFromConfig builds the provider selected by PAYMENT_PROVIDER. Only the
+// local "mock" gateway is built in. The provider has to be named explicitly
+// and PAYMENT_WEBHOOK_SECRET set, so a missing setting can't quietly turn on
+// the mock or accept unsigned webhooks.
+func NewFromConfig(config *initializers.Config) (Provider, error) {
+	if config.PaymentWebhookSecret == "" {
+		return nil, errors.New("PAYMENT_WEBHOOK_SECRET is required")
+	}
+	switch config.PaymentProvider {
+	case "":
+		return nil, errors.New("PAYMENT_PROVIDER is required")
+	case "mock":
+		return NewMockProvider(config.PaymentWebhookSecret), nil
+	default:
+		return nil, fmt.Errorf("unknown payment provider %q", config.PaymentProvider)
+	}
+}
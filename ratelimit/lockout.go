@@ -0,0 +1,77 @@
+package ratelimit
+
+import "time"
+
+// Lockout tracks failed attempts per key and locks the key out with an
+// exponential backoff once Threshold failures happened inside Window.
+type Lockout struct {
+	Store     Store
+	Threshold int
+	Window    time.Duration
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// Attempt records an attempt on key before it is made and returns how long
+// the caller has to wait instead, or 0 when the attempt may go ahead. Counting
+// up front means concurrent attempts each see their own count, so a burst of
+// guesses can't all slip through before the first failure is recorded.
+func (l *Lockout) Attempt(key string) (time.Duration, error) {
+	previous, err := l.Store.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	if wait := l.remaining(previous); wait > 0 {
+		return wait, nil
+	}
+
+	entry, err := l.Store.Increment(key, l.Window)
+	if err != nil {
+		return 0, err
+	}
+	// Past the threshold only the attempt that directly follows an expired
+	// lockout may go ahead; anything that raced it is locked out again.
+	if entry.Count <= l.Threshold || entry.Count == previous.Count+1 {
+		return 0, nil
+	}
+	return l.remaining(entry), nil
+}
+
+// RetryAfter returns how long key is still locked out, or 0, without
+// counting anything.
+func (l *Lockout) RetryAfter(key string) (time.Duration, error) {
+	entry, err := l.Store.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	return l.remaining(entry), nil
+}
+
+// Fail records a failed attempt on key that was let through by RetryAfter.
+func (l *Lockout) Fail(key string) error {
+	_, err := l.Store.Increment(key, l.Window)
+	return err
+}
+
+// Succeed clears the failures of key.
+func (l *Lockout) Succeed(key string) error {
+	return l.Store.Reset(key)
+}
+
+func (l *Lockout) remaining(entry Entry) time.Duration {
+	if entry.Count < l.Threshold {
+		return 0
+	}
+	delay := l.BaseDelay
+	for i := l.Threshold; i < entry.Count && delay < l.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > l.MaxDelay {
+		delay = l.MaxDelay
+	}
+	remaining := time.Until(entry.UpdatedAt.Add(delay))
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
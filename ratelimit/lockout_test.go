@@ -0,0 +1,145 @@
+package ratelimit
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestLockout() (*Lockout, *MemoryStore) {
+	store := NewMemoryStore()
+	return &Lockout{
+		Store:     store,
+		Threshold: 3,
+		Window:    time.Hour,
+		BaseDelay: 10 * time.Second,
+		MaxDelay:  time.Minute,
+	}, store
+}
+
+// near reports whether got is within a second below want, which is all the
+// time that can pass between recording an entry and measuring it.
+func near(got, want time.Duration) bool {
+	return got <= want && got > want-time.Second
+}
+
+func TestLockoutAttemptThreshold(t *testing.T) {
+	lockout, _ := newTestLockout()
+	for i := 1; i <= lockout.Threshold; i++ {
+		if wait, err := lockout.Attempt("k"); err != nil || wait != 0 {
+			t.Fatalf("attempt %d: wait %v, err %v; want it let through", i, wait, err)
+		}
+	}
+	wait, err := lockout.Attempt("k")
+	if err != nil || !near(wait, lockout.BaseDelay) {
+		t.Fatalf("attempt past threshold: wait %v, err %v; want about %v", wait, err, lockout.BaseDelay)
+	}
+	// Attempts while locked out are turned away without being counted.
+	if again, _ := lockout.Attempt("k"); !near(again, lockout.BaseDelay) {
+		t.Fatalf("attempt while locked out: wait %v, want about %v", again, lockout.BaseDelay)
+	}
+	if other, _ := lockout.Attempt("other"); other != 0 {
+		t.Fatalf("other key: wait %v, want 0", other)
+	}
+}
+
+func TestLockoutBackoff(t *testing.T) {
+	lockout, _ := newTestLockout()
+	tests := []struct {
+		count int
+		want  time.Duration
+	}{
+		{0, 0},
+		{2, 0},
+		{3, 10 * time.Second},
+		{4, 20 * time.Second},
+		{5, 40 * time.Second},
+		{6, time.Minute},
+		{50, time.Minute},
+	}
+	for _, tt := range tests {
+		got := lockout.remaining(Entry{Count: tt.count, UpdatedAt: time.Now()})
+		if tt.want == 0 && got != 0 || tt.want != 0 && !near(got, tt.want) {
+			t.Errorf("remaining after %d failures = %v, want %v", tt.count, got, tt.want)
+		}
+	}
+}
+
+func TestLockoutAttemptAfterLockoutExpires(t *testing.T) {
+	lockout, store := newTestLockout()
+	now := time.Now()
+	store.entries["k"] = Entry{Count: 3, UpdatedAt: now.Add(-lockout.BaseDelay), ExpiresAt: now.Add(time.Hour)}
+
+	if wait, err := lockout.Attempt("k"); err != nil || wait != 0 {
+		t.Fatalf("first attempt after lockout: wait %v, err %v; want it let through", wait, err)
+	}
+	// The failure just counted doubles the next lockout.
+	if wait, _ := lockout.Attempt("k"); !near(wait, 2*lockout.BaseDelay) {
+		t.Fatalf("next attempt: wait %v, want about %v", wait, 2*lockout.BaseDelay)
+	}
+}
+
+func TestLockoutConcurrentAttempts(t *testing.T) {
+	lockout, _ := newTestLockout()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if wait, _ := lockout.Attempt("k"); wait == 0 {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if allowed != lockout.Threshold {
+		t.Fatalf("%d concurrent attempts let through, want %d", allowed, lockout.Threshold)
+	}
+}
+
+func TestLockoutSucceedResets(t *testing.T) {
+	lockout, _ := newTestLockout()
+	for i := 0; i <= lockout.Threshold; i++ {
+		lockout.Attempt("k")
+	}
+	if err := lockout.Succeed("k"); err != nil {
+		t.Fatal(err)
+	}
+	if wait, _ := lockout.Attempt("k"); wait != 0 {
+		t.Fatalf("attempt after success: wait %v, want 0", wait)
+	}
+}
+
+func TestLockoutFailAndRetryAfter(t *testing.T) {
+	lockout, _ := newTestLockout()
+	for i := 1; i <= lockout.Threshold; i++ {
+		if wait, _ := lockout.RetryAfter("k"); wait != 0 {
+			t.Fatalf("before failure %d: wait %v, want 0", i, wait)
+		}
+		if err := lockout.Fail("k"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if wait, _ := lockout.RetryAfter("k"); !near(wait, lockout.BaseDelay) {
+		t.Fatalf("after %d failures: wait %v, want about %v", lockout.Threshold, wait, lockout.BaseDelay)
+	}
+}
+
+func TestLockoutWindowExpiry(t *testing.T) {
+	lockout, _ := newTestLockout()
+	lockout.Window = 20 * time.Millisecond
+	for i := 0; i < lockout.Threshold; i++ {
+		lockout.Fail("k")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if wait, _ := lockout.RetryAfter("k"); wait != 0 {
+		t.Fatalf("after the window: wait %v, want 0", wait)
+	}
+	if wait, _ := lockout.Attempt("k"); wait != 0 {
+		t.Fatalf("attempt after the window: wait %v, want 0", wait)
+	}
+}
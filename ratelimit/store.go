@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Entry is a counter that lives until ExpiresAt.
+type Entry struct {
+	Count     int
+	UpdatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// Store keeps fixed-window counters. The in-memory implementation is enough
+// for a single instance; a shared store (Redis, Postgres) can be plugged in
+// by implementing this interface.
+type Store interface {
+	// Increment adds one to key, starting a new window of length window when
+	// the key is missing or expired, and returns the updated entry.
+	Increment(key string, window time.Duration) (Entry, error)
+	// Get returns the current entry for key, or a zero Entry.
+	Get(key string) (Entry, error)
+	Reset(key string) error
+}
+
+type MemoryStore struct {
+	mu        sync.Mutex
+	entries   map[string]Entry
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: map[string]Entry{}}
+}
+
+func (s *MemoryStore) Increment(key string, window time.Duration) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+
+	entry, ok := s.entries[key]
+	if !ok || !now.Before(entry.ExpiresAt) {
+		entry = Entry{ExpiresAt: now.Add(window)}
+	}
+	entry.Count++
+	entry.UpdatedAt = now
+	s.entries[key] = entry
+	return entry, nil
+}
+
+func (s *MemoryStore) Get(key string) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok || !time.Now().Before(entry.ExpiresAt) {
+		return Entry{}, nil
+	}
+	return entry, nil
+}
+
+func (s *MemoryStore) Reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
+
+// sweep drops expired entries at most once a minute so the map does not grow
+// without bound.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	for key, entry := range s.entries {
+		if !now.Before(entry.ExpiresAt) {
+			delete(s.entries, key)
+		}
+	}
+	s.lastSweep = now
+}
@@ -0,0 +1,34 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gmkanat/Go-Shop/controllers"
+	"github.com/gmkanat/Go-Shop/middleware"
+	"github.com/gmkanat/Go-Shop/models"
+)
+
+type AdminRouteController struct {
+	adminController controllers.AdminController
+}
+
+func NewRouteAdminController(adminController controllers.AdminController) AdminRouteController {
+	return AdminRouteController{adminController}
+}
+
+func (ac *AdminRouteController) AdminRoute(rg *gin.RouterGroup) {
+	router := rg.Group("admin", middleware.DeserializeUser())
+	db := ac.adminController.DB
+
+	roles := router.Group("", middleware.RequirePermission(db, models.PermRolesManage))
+	roles.GET("/permissions", ac.adminController.ListPermissions)
+	roles.GET("/roles", ac.adminController.ListRoles)
+	roles.POST("/roles", ac.adminController.CreateRole)
+	roles.PUT("/roles/:id/permissions", ac.adminController.SetRolePermissions)
+	roles.PUT("/users/:id/role", ac.adminController.GrantRole)
+	roles.DELETE("/users/:id/role", ac.adminController.RevokeRole)
+
+	applications := router.Group("/seller-applications", middleware.RequirePermission(db, models.PermSellerApplicationsReview))
+	applications.GET("", ac.adminController.ListSellerApplications)
+	applications.POST("/:id/approve", ac.adminController.ApproveSellerApplication)
+	applications.POST("/:id/reject", ac.adminController.RejectSellerApplication)
+}
@@ -3,6 +3,7 @@ package routes
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/gmkanat/Go-Shop/controllers"
+	"github.com/gmkanat/Go-Shop/initializers"
 	"github.com/gmkanat/Go-Shop/middleware"
 )
 
@@ -15,8 +16,23 @@ func NewAuthRouteController(authController controllers.AuthController) AuthRoute
 }
 
 func (rc *AuthRouteController) AuthRoute(rg *gin.RouterGroup) {
-	router := rg.Group("/auth")
+	config, _ := initializers.LoadConfig(".")
+	router := rg.Group("/auth",
+		middleware.RateLimit(rc.authController.RateLimitStore, config.AuthRateLimit, config.AuthRateWindow, middleware.ClientIPKey))
 	router.POST("/register", rc.authController.SignUpUser)
 	router.POST("/login", rc.authController.SignInUser)
+	router.POST("/login/2fa", rc.authController.VerifyTwoFactorLogin)
+	router.POST("/refresh", rc.authController.RefreshAccessToken)
+	router.GET("/verify", rc.authController.VerifyEmail)
+	router.POST("/verify/resend", rc.authController.ResendVerification)
+	router.POST("/forgot-password", rc.authController.ForgotPassword)
+	router.POST("/reset-password", rc.authController.ResetPassword)
 	router.GET("/logout", middleware.DeserializeUser(), rc.authController.LogoutUser)
+	router.POST("/logout-all", middleware.DeserializeUser(), rc.authController.LogoutAllSessions)
+
+	twoFactor := router.Group("/2fa", middleware.DeserializeUser())
+	twoFactor.POST("/setup", rc.authController.SetupTwoFactor)
+	twoFactor.POST("/enable", rc.authController.EnableTwoFactor)
+	twoFactor.POST("/disable", rc.authController.DisableTwoFactor)
+	twoFactor.POST("/recovery-codes", rc.authController.RegenerateRecoveryCodes)
 }
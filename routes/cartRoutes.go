@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gmkanat/Go-Shop/controllers"
+	"github.com/gmkanat/Go-Shop/middleware"
+)
+
+type CartRouteController struct {
+	cartController controllers.CartController
+}
+
+func NewRouteCartController(cartController controllers.CartController) CartRouteController {
+	return CartRouteController{cartController}
+}
+
+func (cc *CartRouteController) CartRoute(rg *gin.RouterGroup) {
+	router := rg.Group("cart", middleware.DeserializeUser())
+	router.GET("", cc.cartController.GetCart)
+	router.DELETE("", cc.cartController.ClearCart)
+	router.POST("/items", cc.cartController.AddToCart)
+	router.PATCH("/items/:item_id", cc.cartController.UpdateCartItem)
+	router.DELETE("/items/:item_id", cc.cartController.RemoveCartItem)
+	router.GET("/shipping-quotes", cc.cartController.ShippingQuotes)
+	router.POST("/checkout", middleware.RequireVerifiedEmail(), middleware.Idempotency(cc.cartController.DB), cc.cartController.Checkout)
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/gmkanat/Go-Shop/controllers"
 	"github.com/gmkanat/Go-Shop/middleware"
+	"github.com/gmkanat/Go-Shop/models"
 )
 
 type ItemRouteController struct {
@@ -18,10 +19,14 @@ func (ic *ItemRouteController) ItemRoute(rg *gin.RouterGroup) {
 	router := rg.Group("items")
 	router.GET("", ic.itemController.GetItems)
 	router.GET("/:id", ic.itemController.GetItem)
-	router.POST("", middleware.DeserializeUser(), middleware.CheckUserRole(ic.itemController.DB), ic.itemController.CreateItem)
-	router.PUT("/:id", ic.itemController.UpdateItem)
-	router.DELETE("/:id", ic.itemController.DeleteItem)
+	router.POST("", middleware.DeserializeUser(models.ScopeItemsWrite), middleware.RequireVerifiedEmail(), middleware.RequirePermission(ic.itemController.DB, models.PermItemsCreate),
+		middleware.Idempotency(ic.itemController.DB), ic.itemController.CreateItem)
+	router.PUT("/:id", middleware.DeserializeUser(models.ScopeItemsWrite), middleware.RequirePermission(ic.itemController.DB, models.PermItemsUpdate),
+		middleware.CheckItemOwner(ic.itemController.DB), ic.itemController.UpdateItem)
+	router.DELETE("/:id", middleware.DeserializeUser(models.ScopeItemsWrite), middleware.RequirePermission(ic.itemController.DB, models.PermItemsDelete),
+		middleware.CheckItemOwner(ic.itemController.DB), ic.itemController.DeleteItem)
 	router.POST("/rating/:id", middleware.DeserializeUser(), ic.itemController.GiveRatingToItem)
-	router.POST("/comment/:id", middleware.DeserializeUser(), ic.itemController.CommentItem)
-	router.POST("/:id/purchase", middleware.DeserializeUser(), ic.itemController.PurchaseItem)
+	router.POST("/comment/:id", middleware.DeserializeUser(), middleware.Idempotency(ic.itemController.DB), ic.itemController.CommentItem)
+	router.DELETE("/comment/:id", middleware.DeserializeUser(), ic.itemController.DeleteComment)
+	router.POST("/:id/purchase", middleware.DeserializeUser(), middleware.RequireVerifiedEmail(), middleware.Idempotency(ic.itemController.DB), ic.itemController.PurchaseItem)
 }
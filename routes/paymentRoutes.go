@@ -0,0 +1,33 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gmkanat/Go-Shop/controllers"
+	"github.com/gmkanat/Go-Shop/initializers"
+	"github.com/gmkanat/Go-Shop/middleware"
+	"github.com/gmkanat/Go-Shop/models"
+	"github.com/gmkanat/Go-Shop/payments"
+)
+
+type PaymentRouteController struct {
+	paymentController controllers.PaymentController
+}
+
+func NewRoutePaymentController(paymentController controllers.PaymentController) PaymentRouteController {
+	return PaymentRouteController{paymentController}
+}
+
+func (pc *PaymentRouteController) PaymentRoute(rg *gin.RouterGroup) {
+	DB := pc.paymentController.DB
+	rg.POST("orders/:id/pay", middleware.DeserializeUser(), middleware.CheckUserOrder(DB), middleware.Idempotency(DB), pc.paymentController.PayOrder)
+	rg.GET("orders/:id/payments", middleware.DeserializeUser(models.ScopeOrdersRead), middleware.CheckOrderAccess(DB), pc.paymentController.ListOrderPayments)
+
+	router := rg.Group("payments")
+	router.POST("/webhook", pc.paymentController.Webhook)
+	// Anyone can complete a mock payment, so the route is opt-in for
+	// development.
+	config, _ := initializers.LoadConfig(".")
+	if _, ok := pc.paymentController.Provider.(*payments.MockProvider); ok && config.PaymentMockRoutes {
+		router.POST("/mock/:intent_id", pc.paymentController.MockPay)
+	}
+}
@@ -0,0 +1,27 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gmkanat/Go-Shop/controllers"
+	"github.com/gmkanat/Go-Shop/middleware"
+	"github.com/gmkanat/Go-Shop/models"
+)
+
+type ReturnRouteController struct {
+	returnController controllers.ReturnController
+}
+
+func NewRouteReturnController(returnController controllers.ReturnController) ReturnRouteController {
+	return ReturnRouteController{returnController}
+}
+
+func (rc *ReturnRouteController) ReturnRoute(rg *gin.RouterGroup) {
+	DB := rc.returnController.DB
+	router := rg.Group("orders/:id/returns")
+	router.GET("", middleware.DeserializeUser(models.ScopeOrdersRead), middleware.CheckOrderAccess(DB), rc.returnController.ListReturns)
+	router.POST("", middleware.DeserializeUser(), middleware.CheckUserOrder(DB), middleware.Idempotency(DB), rc.returnController.RequestReturn)
+	router.POST("/:return_id/approve", middleware.DeserializeUser(models.ScopeOrdersWrite), middleware.RequirePermission(DB, models.PermOrdersUpdateStatus),
+		middleware.CheckOrderSeller(DB), middleware.Idempotency(DB), rc.returnController.ApproveReturn)
+	router.POST("/:return_id/reject", middleware.DeserializeUser(models.ScopeOrdersWrite), middleware.RequirePermission(DB, models.PermOrdersUpdateStatus),
+		middleware.CheckOrderSeller(DB), rc.returnController.RejectReturn)
+}
@@ -0,0 +1,37 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gmkanat/Go-Shop/controllers"
+	"github.com/gmkanat/Go-Shop/middleware"
+	"github.com/gmkanat/Go-Shop/models"
+)
+
+type SellerRouteController struct {
+	sellerController controllers.SellerController
+}
+
+func NewRouteSellerController(sellerController controllers.SellerController) SellerRouteController {
+	return SellerRouteController{sellerController}
+}
+
+func (sc *SellerRouteController) SellerRoute(rg *gin.RouterGroup) {
+	router := rg.Group("seller")
+	router.GET("/items/low-stock", middleware.DeserializeUser(models.ScopeItemsRead),
+		middleware.RequirePermission(sc.sellerController.DB, models.PermItemsUpdate), sc.sellerController.LowStockItems)
+
+	DB := sc.sellerController.DB
+	methods := router.Group("/shipping-methods", middleware.DeserializeUser(), middleware.RequirePermission(DB, models.PermItemsUpdate))
+	methods.GET("", sc.sellerController.ListShippingMethods)
+	methods.POST("", sc.sellerController.CreateShippingMethod)
+	methods.PUT("/:id", sc.sellerController.UpdateShippingMethod)
+	methods.DELETE("/:id", sc.sellerController.DeleteShippingMethod)
+
+	orders := router.Group("/orders")
+	orders.GET("", middleware.DeserializeUser(models.ScopeOrdersRead), middleware.RequirePermission(DB, models.PermOrdersUpdateStatus), sc.sellerController.ListOrders)
+	orders.GET("/stats", middleware.DeserializeUser(models.ScopeOrdersRead), middleware.RequirePermission(DB, models.PermOrdersUpdateStatus), sc.sellerController.OrderStats)
+	orders.POST("/status", middleware.DeserializeUser(models.ScopeOrdersWrite), middleware.RequirePermission(DB, models.PermOrdersUpdateStatus),
+		middleware.Idempotency(DB), sc.sellerController.BulkUpdateOrderStatus)
+	orders.GET("/:id/packing-slip", middleware.DeserializeUser(models.ScopeOrdersRead), middleware.RequirePermission(DB, models.PermOrdersUpdateStatus),
+		middleware.CheckOrderSeller(DB), sc.sellerController.PackingSlip)
+}
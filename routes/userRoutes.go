@@ -4,6 +4,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/gmkanat/Go-Shop/controllers"
 	"github.com/gmkanat/Go-Shop/middleware"
+	"github.com/gmkanat/Go-Shop/models"
 )
 
 type UserRouteController struct {
@@ -17,4 +18,25 @@ func NewRouteUserController(userController controllers.UserController) UserRoute
 func (uc *UserRouteController) UserRoute(rg *gin.RouterGroup) {
 	router := rg.Group("users")
 	router.GET("/me", middleware.DeserializeUser(), uc.userController.GetMe)
+	router.PATCH("/me", middleware.DeserializeUser(), uc.userController.UpdateMe)
+	router.DELETE("/me", middleware.DeserializeUser(), uc.userController.DeleteMe)
+	router.GET("/me/export", middleware.DeserializeUser(), uc.userController.ExportMe)
+	router.POST("/me/password", middleware.DeserializeUser(), uc.userController.ChangePassword)
+	router.POST("/me/email", middleware.DeserializeUser(), uc.userController.RequestEmailChange)
+	router.GET("/email/confirm", uc.userController.ConfirmEmailChange)
+	router.GET("/me/addresses", middleware.DeserializeUser(), uc.userController.ListAddresses)
+	router.POST("/me/addresses", middleware.DeserializeUser(), uc.userController.CreateAddress)
+	router.PUT("/me/addresses/:id", middleware.DeserializeUser(), uc.userController.UpdateAddress)
+	router.POST("/me/addresses/:id/default", middleware.DeserializeUser(), uc.userController.SetDefaultAddress)
+	router.DELETE("/me/addresses/:id", middleware.DeserializeUser(), uc.userController.DeleteAddress)
+	router.GET("/me/orders", middleware.DeserializeUser(models.ScopeOrdersRead), uc.userController.ListMyOrders)
+	router.GET("/me/sessions", middleware.DeserializeUser(), uc.userController.ListSessions)
+	router.DELETE("/me/sessions/:id", middleware.DeserializeUser(), uc.userController.RevokeSession)
+	router.GET("/me/seller-application", middleware.DeserializeUser(), uc.userController.GetSellerApplication)
+	router.POST("/me/seller-application", middleware.DeserializeUser(), middleware.RequireVerifiedEmail(), uc.userController.ApplyForSeller)
+
+	apiKeys := router.Group("/me/api-keys", middleware.DeserializeUser(), middleware.RequirePermission(uc.userController.DB, models.PermAPIKeysManage))
+	apiKeys.GET("", uc.userController.ListAPIKeys)
+	apiKeys.POST("", uc.userController.CreateAPIKey)
+	apiKeys.DELETE("/:id", uc.userController.RevokeAPIKey)
 }
@@ -0,0 +1,181 @@
+package shipping
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Provider types a seller can configure.
+const (
+	TypeFlat         = "flat"
+	TypeWeightTiered = "weight_tiered"
+)
+
+var ErrNotDeliverable = errors.New("shipping method does not deliver to this destination")
+
+// volumetricDivisor converts cubic centimetres to billable grams, the usual
+// courier rule of 5000 cm³ per kilogram.
+const volumetricDivisor = 5.0
+
+// Parcel is Quantity units of one item.
+type Parcel struct {
+	WeightGrams int
+	LengthCm    float64
+	WidthCm     float64
+	HeightCm    float64
+	Quantity    int
+}
+
+// Shipment is everything one seller sends to one destination.
+type Shipment struct {
+	Country    string
+	PostalCode string
+	Parcels    []Parcel
+	// Subtotal is the price of the goods, for free-shipping thresholds.
+	Subtotal float64
+}
+
+// BillableWeightGrams is the total of each parcel's actual or volumetric
+// weight, whichever is larger.
+func (s Shipment) BillableWeightGrams() int {
+	total := 0.0
+	for _, parcel := range s.Parcels {
+		volumetric := parcel.LengthCm * parcel.WidthCm * parcel.HeightCm / volumetricDivisor
+		total += math.Max(float64(parcel.WeightGrams), volumetric) * float64(parcel.Quantity)
+	}
+	return int(math.Ceil(total))
+}
+
+// Quote is the price of sending a shipment with one method.
+type Quote struct {
+	Label string
+	Cost  float64
+}
+
+// Provider prices shipments.
+type Provider interface {
+	Quote(shipment Shipment) (Quote, error)
+}
+
+// Tier prices shipments up to UpToGrams of billable weight.
+type Tier struct {
+	UpToGrams int     `json:"up_to_grams"`
+	Cost      float64 `json:"cost"`
+}
+
+// Config describes a provider. FreeOver, when positive, wraps the provider
+// so shipments whose subtotal reaches it ship free. An empty Countries list
+// delivers everywhere.
+type Config struct {
+	Type      string
+	Label     string
+	FlatCost  float64
+	Tiers     []Tier
+	FreeOver  float64
+	Countries []string
+}
+
+// New builds the provider described by config.
+func New(config Config) (Provider, error) {
+	var provider Provider
+	switch config.Type {
+	case TypeFlat:
+		if config.FlatCost < 0 {
+			return nil, errors.New("flat rate can't be negative")
+		}
+		provider = FlatRate{Label: config.Label, Cost: config.FlatCost}
+	case TypeWeightTiered:
+		tiered, err := NewWeightTiered(config.Label, config.Tiers)
+		if err != nil {
+			return nil, err
+		}
+		provider = tiered
+	default:
+		return nil, fmt.Errorf("unknown shipping type %q", config.Type)
+	}
+	if config.FreeOver > 0 {
+		provider = FreeOverThreshold{Threshold: config.FreeOver, Base: provider}
+	}
+	if len(config.Countries) > 0 {
+		provider = restricted{countries: config.Countries, base: provider}
+	}
+	return provider, nil
+}
+
+// FlatRate charges the same for every shipment.
+type FlatRate struct {
+	Label string
+	Cost  float64
+}
+
+func (p FlatRate) Quote(shipment Shipment) (Quote, error) {
+	return Quote{Label: p.Label, Cost: p.Cost}, nil
+}
+
+// WeightTiered charges by the first tier the billable weight fits in.
+type WeightTiered struct {
+	Label string
+	Tiers []Tier
+}
+
+func NewWeightTiered(label string, tiers []Tier) (WeightTiered, error) {
+	if len(tiers) == 0 {
+		return WeightTiered{}, errors.New("weight tiered shipping needs at least one tier")
+	}
+	sorted := append([]Tier(nil), tiers...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].UpToGrams < sorted[j].UpToGrams })
+	for i, tier := range sorted {
+		if tier.UpToGrams <= 0 || tier.Cost < 0 {
+			return WeightTiered{}, errors.New("tiers need a positive weight and a non-negative cost")
+		}
+		if i > 0 && tier.UpToGrams == sorted[i-1].UpToGrams {
+			return WeightTiered{}, fmt.Errorf("duplicate tier for %d g", tier.UpToGrams)
+		}
+	}
+	return WeightTiered{Label: label, Tiers: sorted}, nil
+}
+
+func (p WeightTiered) Quote(shipment Shipment) (Quote, error) {
+	weight := shipment.BillableWeightGrams()
+	for _, tier := range p.Tiers {
+		if weight <= tier.UpToGrams {
+			return Quote{Label: p.Label, Cost: tier.Cost}, nil
+		}
+	}
+	return Quote{}, fmt.Errorf("%w: %d g is over the heaviest tier", ErrNotDeliverable, weight)
+}
+
+// FreeOverThreshold ships for free once the goods cost at least Threshold
+// and otherwise charges what Base does.
+type FreeOverThreshold struct {
+	Threshold float64
+	Base      Provider
+}
+
+func (p FreeOverThreshold) Quote(shipment Shipment) (Quote, error) {
+	quote, err := p.Base.Quote(shipment)
+	if err != nil {
+		return Quote{}, err
+	}
+	if shipment.Subtotal >= p.Threshold {
+		quote.Cost = 0
+	}
+	return quote, nil
+}
+
+type restricted struct {
+	countries []string
+	base      Provider
+}
+
+func (p restricted) Quote(shipment Shipment) (Quote, error) {
+	for _, country := range p.countries {
+		if strings.EqualFold(country, shipment.Country) {
+			return p.base.Quote(shipment)
+		}
+	}
+	return Quote{}, ErrNotDeliverable
+}
@@ -0,0 +1,139 @@
+package shipping
+
+import (
+	"errors"
+	"testing"
+)
+
+var testTiers = []Tier{
+	{UpToGrams: 2000, Cost: 10},
+	{UpToGrams: 500, Cost: 5},
+}
+
+// parcel is quantity units of an item small enough that its actual weight
+// is what's billed.
+func parcel(grams, quantity int) Parcel {
+	return Parcel{WeightGrams: grams, LengthCm: 1, WidthCm: 1, HeightCm: 1, Quantity: quantity}
+}
+
+func TestBillableWeightGrams(t *testing.T) {
+	tests := []struct {
+		name    string
+		parcels []Parcel
+		want    int
+	}{
+		{"empty", nil, 0},
+		{"actual weight", []Parcel{parcel(300, 2)}, 600},
+		// 10 x 10 x 10 cm is 200 g volumetric.
+		{"volumetric weight", []Parcel{{WeightGrams: 50, LengthCm: 10, WidthCm: 10, HeightCm: 10, Quantity: 3}}, 600},
+		{"mixed", []Parcel{parcel(100, 1), {WeightGrams: 50, LengthCm: 10, WidthCm: 10, HeightCm: 10, Quantity: 1}}, 300},
+		{"rounds up", []Parcel{{LengthCm: 1, WidthCm: 1, HeightCm: 1.1, Quantity: 1}}, 1},
+	}
+	for _, tt := range tests {
+		if got := (Shipment{Parcels: tt.parcels}).BillableWeightGrams(); got != tt.want {
+			t.Errorf("%s: BillableWeightGrams = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestQuote(t *testing.T) {
+	tiered, err := NewWeightTiered("Tiered", testTiers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flat := FlatRate{Label: "Flat", Cost: 4.5}
+
+	tests := []struct {
+		name     string
+		provider Provider
+		shipment Shipment
+		want     float64
+		wantErr  error
+	}{
+		{"flat", flat, Shipment{Parcels: []Parcel{parcel(5000, 10)}}, 4.5, nil},
+		{"lightest tier", tiered, Shipment{Parcels: []Parcel{parcel(100, 1)}}, 5, nil},
+		{"tier boundary", tiered, Shipment{Parcels: []Parcel{parcel(250, 2)}}, 5, nil},
+		{"next tier", tiered, Shipment{Parcels: []Parcel{parcel(501, 1)}}, 10, nil},
+		{"heaviest tier", tiered, Shipment{Parcels: []Parcel{parcel(1000, 2)}}, 10, nil},
+		{"over heaviest tier", tiered, Shipment{Parcels: []Parcel{parcel(2001, 1)}}, 0, ErrNotDeliverable},
+		{"under free threshold", FreeOverThreshold{Threshold: 50, Base: flat}, Shipment{Subtotal: 49.99}, 4.5, nil},
+		{"at free threshold", FreeOverThreshold{Threshold: 50, Base: flat}, Shipment{Subtotal: 50}, 0, nil},
+		{"free threshold keeps base errors", FreeOverThreshold{Threshold: 50, Base: tiered},
+			Shipment{Subtotal: 100, Parcels: []Parcel{parcel(3000, 1)}}, 0, ErrNotDeliverable},
+		{"allowed country", restricted{countries: []string{"KZ", "DE"}, base: flat}, Shipment{Country: "de"}, 4.5, nil},
+		{"other country", restricted{countries: []string{"KZ", "DE"}, base: flat}, Shipment{Country: "US"}, 0, ErrNotDeliverable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quote, err := tt.provider.Quote(tt.shipment)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Quote error = %v, want %v", err, tt.wantErr)
+			}
+			if quote.Cost != tt.want {
+				t.Errorf("Quote cost = %v, want %v", quote.Cost, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewWeightTieredRejectsBadTiers(t *testing.T) {
+	tests := []struct {
+		name  string
+		tiers []Tier
+	}{
+		{"no tiers", nil},
+		{"zero weight", []Tier{{UpToGrams: 0, Cost: 5}}},
+		{"negative cost", []Tier{{UpToGrams: 500, Cost: -1}}},
+		{"duplicate weight", []Tier{{UpToGrams: 500, Cost: 5}, {UpToGrams: 500, Cost: 6}}},
+	}
+	for _, tt := range tests {
+		if _, err := NewWeightTiered("Tiered", tt.tiers); err == nil {
+			t.Errorf("%s: NewWeightTiered succeeded, want an error", tt.name)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   Config
+		shipment Shipment
+		want     float64
+		wantErr  error
+	}{
+		{"flat", Config{Type: TypeFlat, FlatCost: 3}, Shipment{Country: "KZ"}, 3, nil},
+		{"weight tiered", Config{Type: TypeWeightTiered, Tiers: testTiers},
+			Shipment{Country: "KZ", Parcels: []Parcel{parcel(800, 1)}}, 10, nil},
+		{"free over threshold", Config{Type: TypeFlat, FlatCost: 3, FreeOver: 20},
+			Shipment{Country: "KZ", Subtotal: 20}, 0, nil},
+		{"restricted and free", Config{Type: TypeFlat, FlatCost: 3, FreeOver: 20, Countries: []string{"KZ"}},
+			Shipment{Country: "KZ", Subtotal: 25}, 0, nil},
+		{"restricted elsewhere", Config{Type: TypeFlat, FlatCost: 3, FreeOver: 20, Countries: []string{"KZ"}},
+			Shipment{Country: "FR", Subtotal: 25}, 0, ErrNotDeliverable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, err := New(tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			quote, err := provider.Quote(tt.shipment)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Quote error = %v, want %v", err, tt.wantErr)
+			}
+			if quote.Cost != tt.want {
+				t.Errorf("Quote cost = %v, want %v", quote.Cost, tt.want)
+			}
+		})
+	}
+
+	for _, config := range []Config{
+		{Type: "courier"},
+		{Type: TypeFlat, FlatCost: -1},
+		{Type: TypeWeightTiered},
+	} {
+		if _, err := New(config); err == nil {
+			t.Errorf("New(%+v) succeeded, want an error", config)
+		}
+	}
+}
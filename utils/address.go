@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+var (
+	ErrInvalidCountry    = errors.New("country must be an ISO 3166-1 alpha-2 code")
+	ErrInvalidPostalCode = errors.New("postal code is not valid for this country")
+)
+
+// postalCodeFormats are the formats of countries we check strictly. Postal
+// codes of other countries only have to look plausible.
+var postalCodeFormats = map[string]*regexp.Regexp{
+	"US": regexp.MustCompile(`^\d{5}(-\d{4})?$`),
+	"CA": regexp.MustCompile(`^[A-Z]\d[A-Z] ?\d[A-Z]\d$`),
+	"GB": regexp.MustCompile(`^[A-Z]{1,2}\d[A-Z\d]? ?\d[A-Z]{2}$`),
+	"DE": regexp.MustCompile(`^\d{5}$`),
+	"FR": regexp.MustCompile(`^\d{5}$`),
+	"IT": regexp.MustCompile(`^\d{5}$`),
+	"ES": regexp.MustCompile(`^\d{5}$`),
+	"NL": regexp.MustCompile(`^\d{4} ?[A-Z]{2}$`),
+	"PL": regexp.MustCompile(`^\d{2}-\d{3}$`),
+	"KZ": regexp.MustCompile(`^(\d{6}|[A-Z]\d{2}[A-Z]\d[A-Z]\d)$`),
+	"RU": regexp.MustCompile(`^\d{6}$`),
+	"CN": regexp.MustCompile(`^\d{6}$`),
+	"IN": regexp.MustCompile(`^\d{6}$`),
+	"JP": regexp.MustCompile(`^\d{3}-?\d{4}$`),
+	"BR": regexp.MustCompile(`^\d{5}-?\d{3}$`),
+	"AU": regexp.MustCompile(`^\d{4}$`),
+}
+
+var genericPostalCode = regexp.MustCompile(`^[A-Z0-9][A-Z0-9 -]{1,9}$`)
+
+// noPostalCode lists countries that don't use postal codes, where the postal
+// code may be left empty.
+var noPostalCode = map[string]bool{
+	"AE": true, "AG": true, "AO": true, "AW": true, "BF": true, "BI": true, "BJ": true, "BO": true,
+	"BS": true, "BW": true, "BZ": true, "CD": true, "CF": true, "CG": true, "CI": true, "CK": true,
+	"CM": true, "DJ": true, "DM": true, "ER": true, "FJ": true, "GA": true, "GD": true, "GH": true,
+	"GM": true, "GQ": true, "GY": true, "HK": true, "JM": true, "KI": true, "KM": true, "KN": true,
+	"KP": true, "LC": true, "ML": true, "MO": true, "MR": true, "MW": true, "NR": true, "NU": true,
+	"QA": true, "RW": true, "SB": true, "SC": true, "SL": true, "SR": true, "ST": true, "SY": true,
+	"TD": true, "TG": true, "TK": true, "TL": true, "TO": true, "TV": true, "UG": true, "VU": true,
+	"YE": true, "ZW": true,
+}
+
+// notISOCountries are codes the language package accepts as countries that
+// are not assigned ISO 3166-1 alpha-2 codes: exceptionally reserved codes and
+// withdrawn ones without a single successor.
+var notISOCountries = map[string]bool{
+	"AC": true, "CP": true, "DG": true, "EA": true, "IC": true, "TA": true,
+	"AN": true, "CS": true, "SU": true, "YU": true,
+}
+
+// NormalizeCountry validates an ISO 3166-1 alpha-2 code and returns it in
+// canonical form, so aliases such as "uk" become "GB".
+func NormalizeCountry(country string) (string, error) {
+	country = strings.ToUpper(strings.TrimSpace(country))
+	region, err := language.ParseRegion(country)
+	if err != nil || len(country) != 2 {
+		return "", ErrInvalidCountry
+	}
+	region = region.Canonicalize()
+	if !region.IsCountry() || region.IsPrivateUse() || notISOCountries[region.String()] {
+		return "", ErrInvalidCountry
+	}
+	return region.String(), nil
+}
+
+// NormalizeAddress upper-cases and validates a country code and postal code,
+// returning them in their canonical form.
+func NormalizeAddress(country, postalCode string) (string, string, error) {
+	country, err := NormalizeCountry(country)
+	if err != nil {
+		return "", "", err
+	}
+
+	postalCode = strings.ToUpper(strings.Join(strings.Fields(postalCode), " "))
+	if postalCode == "" && noPostalCode[country] {
+		return country, "", nil
+	}
+	format, ok := postalCodeFormats[country]
+	if !ok {
+		format = genericPostalCode
+	}
+	if !format.MatchString(postalCode) {
+		return "", "", ErrInvalidPostalCode
+	}
+	return country, postalCode, nil
+}
@@ -0,0 +1,250 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// KeySet holds the key used to sign new tokens and every key accepted when
+// verifying them, indexed by "kid". Keeping retired public keys in the set
+// lets tokens signed before a rotation stay valid until they expire.
+//
+// Single-purpose tokens (email links, 2FA challenges) are signed with a
+// separate HMAC key that is never published, so a service verifying access
+// tokens through the JWKS can't be handed one of them instead.
+type KeySet struct {
+	signingMethod jwt.SigningMethod
+	signingKeyID  string
+	signingKey    interface{}
+	verifyKeys    map[string]verificationKey
+	purposeKey    []byte
+}
+
+type verificationKey struct {
+	method jwt.SigningMethod
+	key    interface{}
+	// notAfter, when set, limits the key to tokens expiring by then.
+	notAfter time.Time
+}
+
+// NewHMACKeySet returns a key set that signs and verifies with HS256 and a
+// shared secret.
+func NewHMACKeySet(secret string) *KeySet {
+	return &KeySet{
+		signingMethod: jwt.SigningMethodHS256,
+		signingKey:    []byte(secret),
+		verifyKeys: map[string]verificationKey{
+			"": {method: jwt.SigningMethodHS256, key: []byte(secret)},
+		},
+		purposeKey: derivePurposeKey(secret),
+	}
+}
+
+// UsePurposeSecret sets the shared secret single-purpose tokens are signed
+// with. Key sets built by LoadKeySet need it before purpose tokens can be
+// issued.
+func (ks *KeySet) UsePurposeSecret(secret string) {
+	ks.purposeKey = derivePurposeKey(secret)
+}
+
+// AcceptRetiredHMAC keeps HS256 tokens signed with secret valid after
+// switching to an asymmetric algorithm, but only those expiring by until, so
+// users are not logged out by the switch and the old secret stops working
+// once their tokens have run out.
+func (ks *KeySet) AcceptRetiredHMAC(secret string, until time.Time) {
+	ks.verifyKeys[""] = verificationKey{method: jwt.SigningMethodHS256, key: []byte(secret), notAfter: until}
+}
+
+// derivePurposeKey derives the purpose token key from the shared secret so it
+// never equals the HS256 access token key.
+func derivePurposeKey(secret string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("go-shop purpose tokens"))
+	return mac.Sum(nil)
+}
+
+// LoadKeySet reads an RS256 or EdDSA private key from privateKeyPath and the
+// extra public keys listed in verifyKeys as "kid=path/to/public.pem".
+func LoadKeySet(algorithm string, privateKeyPath string, keyID string, verifyKeys []string) (*KeySet, error) {
+	if keyID == "" {
+		return nil, fmt.Errorf("a key id is required for %s tokens", algorithm)
+	}
+
+	pemBytes, err := os.ReadFile(privateKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading private key failed: %w", err)
+	}
+
+	keys := &KeySet{signingKeyID: keyID, verifyKeys: map[string]verificationKey{}}
+	switch algorithm {
+	case "RS256":
+		privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(pemBytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing RSA private key failed: %w", err)
+		}
+		keys.signingMethod = jwt.SigningMethodRS256
+		keys.signingKey = privateKey
+		keys.verifyKeys[keyID] = verificationKey{method: jwt.SigningMethodRS256, key: &privateKey.PublicKey}
+	case "EdDSA":
+		privateKey, err := jwt.ParseEdPrivateKeyFromPEM(pemBytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing Ed25519 private key failed: %w", err)
+		}
+		keys.signingMethod = jwt.SigningMethodEdDSA
+		keys.signingKey = privateKey
+		keys.verifyKeys[keyID] = verificationKey{method: jwt.SigningMethodEdDSA, key: privateKey.(ed25519.PrivateKey).Public()}
+	default:
+		return nil, fmt.Errorf("unsupported token algorithm %q", algorithm)
+	}
+
+	for _, entry := range verifyKeys {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		kid, path, ok := strings.Cut(entry, "=")
+		if !ok || kid == "" || path == "" {
+			return nil, fmt.Errorf("verification key %q must look like kid=path", entry)
+		}
+		key, err := loadPublicKey(path)
+		if err != nil {
+			return nil, fmt.Errorf("loading verification key %s failed: %w", kid, err)
+		}
+		keys.verifyKeys[kid] = key
+	}
+
+	return keys, nil
+}
+
+func loadPublicKey(path string) (verificationKey, error) {
+	pemBytes, err := os.ReadFile(path)
+	if err != nil {
+		return verificationKey{}, err
+	}
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return verificationKey{}, fmt.Errorf("no PEM data found")
+	}
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return verificationKey{}, err
+	}
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		return verificationKey{method: jwt.SigningMethodRS256, key: key}, nil
+	case ed25519.PublicKey:
+		return verificationKey{method: jwt.SigningMethodEdDSA, key: key}, nil
+	default:
+		return verificationKey{}, fmt.Errorf("unsupported public key type %T", publicKey)
+	}
+}
+
+// Algorithm is the JWS algorithm new tokens are signed with.
+func (ks *KeySet) Algorithm() string {
+	return ks.signingMethod.Alg()
+}
+
+// sign signs token with the current signing key and sets its "kid" header.
+func (ks *KeySet) sign(claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(ks.signingMethod, claims)
+	if ks.signingKeyID != "" {
+		token.Header["kid"] = ks.signingKeyID
+	}
+	return token.SignedString(ks.signingKey)
+}
+
+// keyFunc picks the verification key named by the token's "kid" header and
+// refuses any algorithm other than the one registered for that key.
+func (ks *KeySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := ks.verifyKeys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected method: %s", token.Header["alg"])
+	}
+	if !key.notAfter.IsZero() {
+		claims, _ := token.Claims.(jwt.MapClaims)
+		if claims == nil || unixClaim(claims["exp"]).After(key.notAfter) {
+			return nil, fmt.Errorf("signing key %q has been retired", kid)
+		}
+	}
+	return key.key, nil
+}
+
+// signPurpose signs a single-purpose token with the internal HMAC key.
+func (ks *KeySet) signPurpose(claims jwt.MapClaims) (string, error) {
+	if len(ks.purposeKey) == 0 {
+		return "", fmt.Errorf("no purpose token secret configured")
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["typ"] = purposeTokenType
+	return token.SignedString(ks.purposeKey)
+}
+
+// purposeKeyFunc only accepts tokens signed by signPurpose.
+func (ks *KeySet) purposeKeyFunc(token *jwt.Token) (interface{}, error) {
+	if token.Method.Alg() != jwt.SigningMethodHS256.Alg() || token.Header["typ"] != purposeTokenType {
+		return nil, fmt.Errorf("unexpected method: %s", token.Header["alg"])
+	}
+	if len(ks.purposeKey) == 0 {
+		return nil, fmt.Errorf("no purpose token secret configured")
+	}
+	return ks.purposeKey, nil
+}
+
+// JWK is a public key in RFC 7517 format.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS returns the public verification keys. Shared HMAC secrets are never
+// published.
+func (ks *KeySet) JWKS() []JWK {
+	jwks := []JWK{}
+	for kid, key := range ks.verifyKeys {
+		switch publicKey := key.key.(type) {
+		case *rsa.PublicKey:
+			jwks = append(jwks, JWK{
+				Kty: "RSA",
+				Kid: kid,
+				Use: "sig",
+				Alg: key.method.Alg(),
+				N:   base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			jwks = append(jwks, JWK{
+				Kty: "OKP",
+				Kid: kid,
+				Use: "sig",
+				Alg: key.method.Alg(),
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(publicKey),
+			})
+		}
+	}
+	sort.Slice(jwks, func(i, j int) bool { return jwks[i].Kid < jwks[j].Kid })
+	return jwks
+}
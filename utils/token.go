@@ -1,44 +1,134 @@
 package utils
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"time"
 
 	"github.com/golang-jwt/jwt"
 )
 
+// TokenClaims holds the registered claims of an access token that callers
+// need for session bookkeeping and revocation.
+type TokenClaims struct {
+	Subject   interface{}
+	ID        string
+	SessionID string
+	Purpose   string
+	Audience  string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
 func GenerateToken(
 	ttl time.Duration,
 	payload interface{},
-	secretJWTKey string,
-) (string, error) {
-	token := jwt.New(jwt.SigningMethodHS256)
+	keys *KeySet,
+) (string, *TokenClaims, error) {
+	return generateToken(ttl, payload, nil, keys)
+}
+
+// GenerateSessionToken is GenerateToken with a "sid" claim binding the
+// access token to a login session.
+func GenerateSessionToken(
+	ttl time.Duration,
+	payload interface{},
+	sessionID string,
+	keys *KeySet,
+) (string, *TokenClaims, error) {
+	return generateToken(ttl, payload, jwt.MapClaims{"sid": sessionID}, keys)
+}
+
+// GeneratePurposeToken signs a single-purpose token such as an email
+// verification link. Purpose tokens carry their own "typ" and "aud" and are
+// signed with the key set's internal key, so they can never be used as access
+// tokens, here or by services verifying against the JWKS.
+func GeneratePurposeToken(
+	ttl time.Duration,
+	payload interface{},
+	purpose string,
+	keys *KeySet,
+) (string, *TokenClaims, error) {
+	extra := jwt.MapClaims{"purpose": purpose, "aud": purposeTokenAudience}
+	return buildToken(ttl, payload, extra, keys.signPurpose)
+}
+
+// purposeTokenType and purposeTokenAudience tell purpose tokens apart from
+// access tokens.
+const (
+	purposeTokenType     = "purpose+jwt"
+	purposeTokenAudience = "go-shop:purpose"
+)
+
+func generateToken(
+	ttl time.Duration,
+	payload interface{},
+	extra jwt.MapClaims,
+	keys *KeySet,
+) (string, *TokenClaims, error) {
+	return buildToken(ttl, payload, extra, keys.sign)
+}
+
+func buildToken(
+	ttl time.Duration,
+	payload interface{},
+	extra jwt.MapClaims,
+	sign func(jwt.MapClaims) (string, error),
+) (string, *TokenClaims, error) {
+	jti, err := GenerateOpaqueToken(16)
+	if err != nil {
+		return "", nil, err
+	}
 
 	now := time.Now().UTC()
-	claims := token.Claims.(jwt.MapClaims)
+	claims := jwt.MapClaims{}
+	for key, value := range extra {
+		claims[key] = value
+	}
 
 	claims["sub"] = payload
+	claims["jti"] = jti
 	claims["exp"] = now.Add(ttl).Unix()
 	claims["iat"] = now.Unix()
 	claims["nbf"] = now.Unix()
 
-	tokenString, err := token.SignedString([]byte(secretJWTKey))
+	tokenString, err := sign(claims)
 
 	if err != nil {
-		return "", fmt.Errorf("generating JWT Token failed: %w", err)
+		return "", nil, fmt.Errorf("generating JWT Token failed: %w", err)
 	}
 
-	return tokenString, nil
+	return tokenString, mapTokenClaims(claims), nil
 }
 
-func ValidateToken(token string, signedJWTKey string) (interface{}, error) {
-	tok, err := jwt.Parse(token, func(jwtToken *jwt.Token) (interface{}, error) {
-		if _, ok := jwtToken.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected method: %s", jwtToken.Header["alg"])
-		}
+func ValidateToken(token string, keys *KeySet) (*TokenClaims, error) {
+	claims, err := parseToken(token, keys.keyFunc)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != "" || claims.Audience != "" {
+		return nil, fmt.Errorf("invalid token claim")
+	}
+	return claims, nil
+}
+
+// ValidatePurposeToken validates a token created by GeneratePurposeToken
+// for the same purpose.
+func ValidatePurposeToken(token string, purpose string, keys *KeySet) (*TokenClaims, error) {
+	claims, err := parseToken(token, keys.purposeKeyFunc)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != purpose || claims.Audience != purposeTokenAudience {
+		return nil, fmt.Errorf("invalid token claim")
+	}
+	return claims, nil
+}
 
-		return []byte(signedJWTKey), nil
-	})
+func parseToken(token string, keyFunc jwt.Keyfunc) (*TokenClaims, error) {
+	tok, err := jwt.Parse(token, keyFunc)
 	if err != nil {
 		return nil, fmt.Errorf("invalidate token: %w", err)
 	}
@@ -48,5 +138,44 @@ func ValidateToken(token string, signedJWTKey string) (interface{}, error) {
 		return nil, fmt.Errorf("invalid token claim")
 	}
 
-	return claims["sub"], nil
+	return mapTokenClaims(claims), nil
+}
+
+func mapTokenClaims(claims jwt.MapClaims) *TokenClaims {
+	result := &TokenClaims{Subject: claims["sub"]}
+	result.ID, _ = claims["jti"].(string)
+	result.SessionID, _ = claims["sid"].(string)
+	result.Purpose, _ = claims["purpose"].(string)
+	result.Audience, _ = claims["aud"].(string)
+	result.IssuedAt = unixClaim(claims["iat"])
+	result.ExpiresAt = unixClaim(claims["exp"])
+	return result
+}
+
+// unixClaim reads a NumericDate claim, which is an int64 on freshly signed
+// tokens and a float64 once it has been through JSON.
+func unixClaim(value interface{}) time.Time {
+	switch v := value.(type) {
+	case int64:
+		return time.Unix(v, 0).UTC()
+	case float64:
+		return time.Unix(int64(v), 0).UTC()
+	}
+	return time.Time{}
+}
+
+// GenerateOpaqueToken returns a random hex string suitable for refresh tokens
+// and other secrets that are only ever stored hashed.
+func GenerateOpaqueToken(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generating random token failed: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// HashToken returns the hex encoded SHA-256 digest of token.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 seed of the RFC 6238 appendix B test vectors.
+var rfc6238Secret = []byte("12345678901234567890")
+
+func TestTOTPCodeRFC6238Vectors(t *testing.T) {
+	// The RFC lists 8 digit codes; a 6 digit code is their last 6 digits.
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		if got := totpCode(rfc6238Secret, tt.unix/totpPeriod); got != tt.code {
+			t.Errorf("totpCode at %d = %s, want %s", tt.unix, got, tt.code)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret := totpEncoding.EncodeToString(rfc6238Secret)
+	at := time.Unix(1111111111, 0)
+	step := at.Unix() / totpPeriod
+
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current step", secret, totpCode(rfc6238Secret, step), step, true},
+		{"one step behind", secret, totpCode(rfc6238Secret, step-1), step - 1, true},
+		{"one step ahead", secret, totpCode(rfc6238Secret, step+1), step + 1, true},
+		{"two steps behind", secret, totpCode(rfc6238Secret, step-2), 0, false},
+		{"two steps ahead", secret, totpCode(rfc6238Secret, step+2), 0, false},
+		{"surrounding spaces", secret, " " + totpCode(rfc6238Secret, step) + " ", step, true},
+		{"lower case secret", "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", totpCode(rfc6238Secret, step), step, true},
+		{"too short", secret, "12345", 0, false},
+		{"too long", secret, "1234567", 0, false},
+		{"wrong code", secret, "000000", 0, false},
+		{"invalid secret", "not base32!", totpCode(rfc6238Secret, step), 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStep, ok := ValidateTOTP(tt.secret, tt.code, at)
+			if ok != tt.wantOK || gotStep != tt.wantStep {
+				t.Errorf("ValidateTOTP = (%d, %v), want (%d, %v)", gotStep, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}